@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/mileusna/useragent"
 	http "github.com/saucesteals/fhttp"
@@ -28,6 +29,9 @@ type Options struct {
 	Credentials         Credentials
 	BrowserUserDataPath string
 	BrowserBinary       string
+
+	// HAR, when set, captures every exchange made through Do
+	HAR *HARRecorder
 }
 
 type API struct {
@@ -87,6 +91,10 @@ func (a *API) GetCredentials() Credentials {
 }
 
 func (a *API) Do(req *http.Request) (*http.Response, error) {
+	if a.HAR != nil {
+		return a.doRecorded(req)
+	}
+
 	res, err := a.client.Do(req)
 	if err != nil {
 		return nil, err
@@ -99,3 +107,34 @@ func (a *API) Do(req *http.Request) (*http.Response, error) {
 
 	return res, nil
 }
+
+func (a *API) doRecorded(req *http.Request) (*http.Response, error) {
+	reqBody, body, err := readBody(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	req.Body = body
+
+	started := time.Now()
+	res, err := a.client.Do(req)
+	if err != nil {
+		a.HAR.recordError(req, reqBody, started, err)
+		return nil, err
+	}
+
+	resBody, body, err := readBody(res.Body)
+	if err != nil {
+		a.HAR.recordError(req, reqBody, started, err)
+		return nil, err
+	}
+	res.Body = &recordedBody{ReadCloser: body, release: func() { a.HAR.release(req) }}
+
+	a.HAR.record(req, reqBody, res, resBody, started, time.Since(started))
+
+	if res.StatusCode == http.StatusTooManyRequests {
+		res.Body.Close()
+		return nil, ErrRateLimited
+	}
+
+	return res, nil
+}
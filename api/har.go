@@ -0,0 +1,522 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	http "github.com/saucesteals/fhttp"
+)
+
+var (
+	harRedacted = "[REDACTED]"
+)
+
+// HARRedaction controls which parts of a recorded exchange are masked before
+// they are written to a HAR file.
+type HARRedaction struct {
+	// Headers are matched case-insensitively against request and response
+	// header names.
+	Headers []string
+	// QueryParams are matched against URL query parameter names.
+	QueryParams []string
+	// BodyFields are matched case-insensitively against JSON object keys at any
+	// depth of request, response and cleartext bodies.
+	BodyFields []string
+	// Cookies masks every cookie value.
+	Cookies bool
+	// OmitCleartext drops decrypted payload annotations entirely.
+	OmitCleartext bool
+}
+
+// DefaultHARRedaction masks credentials, session cookies and card details
+// while keeping enough of each exchange to debug it.
+func DefaultHARRedaction() HARRedaction {
+	return HARRedaction{
+		Headers: []string{
+			"cookie",
+			"set-cookie",
+			"authorization",
+			"access-token",
+			"x-gw-client-public-key",
+			"x-device-fingerprint",
+			"ewa-fingerprint",
+		},
+		BodyFields: []string{
+			"password",
+			"pin",
+			"cvv",
+			"userEnteredCvv",
+			"token",
+			"encryptedPassphrase",
+			"passphraseAuthenticationToken",
+			"pinAuthenticationToken",
+			"headerFRCookie",
+			"headerForgeRockCookie",
+			"upgradedForgeRockCookie",
+			"rsaToken",
+			"expressToken",
+			"expressCheckoutToken",
+			"userName",
+		},
+		Cookies: true,
+	}
+}
+
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type HARContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type HARTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+type HAREntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+// HARRecorder captures every exchange made through API.Do. Encrypted endpoints
+// can attach their cleartext payloads with AnnotateRequest and
+// AnnotateResponse after the fact. A request is tracked until its response
+// body is closed, or until Done if it was held.
+type HARRecorder struct {
+	mu        sync.Mutex
+	redaction HARRedaction
+	entries   []*HAREntry
+	pending   map[*http.Request]*HAREntry
+	cleartext map[*http.Request][]byte
+	held      map[*http.Request]bool
+}
+
+func NewHARRecorder(redaction HARRedaction) *HARRecorder {
+	return &HARRecorder{
+		redaction: redaction,
+		pending:   map[*http.Request]*HAREntry{},
+		cleartext: map[*http.Request][]byte{},
+		held:      map[*http.Request]bool{},
+	}
+}
+
+func (r *HARRecorder) record(req *http.Request, reqBody []byte, res *http.Response, resBody []byte, started time.Time, elapsed time.Duration) {
+	ms := float64(elapsed.Microseconds()) / 1000
+
+	entry := &HAREntry{
+		StartedDateTime: started.Format(time.RFC3339Nano),
+		Time:            ms,
+		Request:         r.harRequest(req, reqBody),
+		Timings:         HARTimings{Wait: ms},
+	}
+
+	if res != nil {
+		entry.Response = r.harResponse(res, resBody)
+	}
+
+	r.mu.Lock()
+	r.annotateRequest(entry, req)
+	r.entries = append(r.entries, entry)
+	r.pending[req] = entry
+	r.mu.Unlock()
+}
+
+func (r *HARRecorder) recordError(req *http.Request, reqBody []byte, started time.Time, err error) {
+	entry := &HAREntry{
+		StartedDateTime: started.Format(time.RFC3339Nano),
+		Time:            float64(time.Since(started).Microseconds()) / 1000,
+		Request:         r.harRequest(req, reqBody),
+		Comment:         "error: " + err.Error(),
+	}
+
+	r.mu.Lock()
+	r.annotateRequest(entry, req)
+	r.entries = append(r.entries, entry)
+	r.forget(req)
+	r.mu.Unlock()
+}
+
+// AnnotateRequest records the cleartext body of an encrypted request. It may be
+// called before the request is sent.
+func (r *HARRecorder) AnnotateRequest(req *http.Request, cleartext []byte) {
+	if r == nil || r.redaction.OmitCleartext {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cleartext[req] = cleartext
+	if entry, ok := r.pending[req]; ok {
+		r.annotateRequest(entry, req)
+	}
+}
+
+func (r *HARRecorder) annotateRequest(entry *HAREntry, req *http.Request) {
+	cleartext, ok := r.cleartext[req]
+	if !ok || entry.Request.PostData == nil {
+		return
+	}
+
+	delete(r.cleartext, req)
+	entry.Request.PostData.Comment = "cleartext: " + r.redactBody(cleartext)
+}
+
+// AnnotateResponse records the decrypted body of an encrypted response.
+func (r *HARRecorder) AnnotateResponse(req *http.Request, cleartext []byte) {
+	if r == nil || r.redaction.OmitCleartext {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, ok := r.pending[req]
+	if !ok {
+		return
+	}
+
+	entry.Response.Content.Comment = "cleartext: " + r.redactBody(cleartext)
+}
+
+// Hold keeps tracking req after its response body is closed, for responses
+// annotated once they are decoded. The caller must call Done.
+func (r *HARRecorder) Hold(req *http.Request) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.held[req] = true
+}
+
+// Done stops tracking a held request once its response has been annotated,
+// if at all.
+func (r *HARRecorder) Done(req *http.Request) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.forget(req)
+}
+
+// release stops tracking req once its response body is closed, unless it is
+// held.
+func (r *HARRecorder) release(req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.held[req] {
+		r.forget(req)
+	}
+}
+
+func (r *HARRecorder) forget(req *http.Request) {
+	delete(r.pending, req)
+	delete(r.cleartext, req)
+	delete(r.held, req)
+}
+
+// recordedBody releases its request from the recorder when it is closed.
+type recordedBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (b *recordedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+func (r *HARRecorder) HAR() HAR {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entries := make([]HAREntry, len(r.entries))
+	for i, entry := range r.entries {
+		entries[i] = *entry
+	}
+
+	return HAR{
+		Log: HARLog{
+			Version: "1.2",
+			Creator: HARCreator{Name: "eno", Version: "1.0"},
+			Entries: entries,
+		},
+	}
+}
+
+func (r *HARRecorder) WriteTo(w io.Writer) (int64, error) {
+	contents, err := json.MarshalIndent(r.HAR(), "", "  ")
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := w.Write(contents)
+	return int64(n), err
+}
+
+// Save writes the recorded session to path, replacing any previous file.
+func (r *HARRecorder) Save(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := r.WriteTo(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func (r *HARRecorder) harRequest(req *http.Request, body []byte) HARRequest {
+	u := *req.URL
+	query := u.Query()
+	for key := range query {
+		if r.matches(r.redaction.QueryParams, key) {
+			query.Set(key, harRedacted)
+		}
+	}
+	u.RawQuery = query.Encode()
+
+	harReq := HARRequest{
+		Method:      req.Method,
+		URL:         u.String(),
+		HTTPVersion: req.Proto,
+		Cookies:     []HARNameValue{},
+		Headers:     r.harHeaders(req.Header),
+		QueryString: r.harValues(query),
+		HeadersSize: -1,
+		BodySize:    len(body),
+	}
+
+	for _, cookie := range req.Cookies() {
+		harReq.Cookies = append(harReq.Cookies, r.harCookie(cookie))
+	}
+
+	if body != nil {
+		harReq.PostData = &HARPostData{
+			MimeType: req.Header.Get("Content-Type"),
+			Text:     r.redactBody(body),
+		}
+	}
+
+	return harReq
+}
+
+func (r *HARRecorder) harResponse(res *http.Response, body []byte) HARResponse {
+	harRes := HARResponse{
+		Status:      res.StatusCode,
+		StatusText:  http.StatusText(res.StatusCode),
+		HTTPVersion: res.Proto,
+		Cookies:     []HARNameValue{},
+		Headers:     r.harHeaders(res.Header),
+		Content: HARContent{
+			Size:     len(body),
+			MimeType: res.Header.Get("Content-Type"),
+			Text:     r.redactBody(body),
+		},
+		RedirectURL: res.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    len(body),
+	}
+
+	for _, cookie := range res.Cookies() {
+		harRes.Cookies = append(harRes.Cookies, r.harCookie(cookie))
+	}
+
+	return harRes
+}
+
+func (r *HARRecorder) harCookie(cookie *http.Cookie) HARNameValue {
+	value := cookie.Value
+	if r.redaction.Cookies {
+		value = harRedacted
+	}
+
+	return HARNameValue{Name: cookie.Name, Value: value}
+}
+
+func (r *HARRecorder) harHeaders(header http.Header) []HARNameValue {
+	headers := []HARNameValue{}
+	for name, values := range header {
+		if name == http.HeaderOrderKey || name == http.PHeaderOrderKey {
+			continue
+		}
+
+		for _, value := range values {
+			if r.matches(r.redaction.Headers, name) {
+				value = harRedacted
+			}
+
+			headers = append(headers, HARNameValue{Name: name, Value: value})
+		}
+	}
+
+	slices.SortStableFunc(headers, func(a, b HARNameValue) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return headers
+}
+
+func (r *HARRecorder) harValues(values url.Values) []HARNameValue {
+	pairs := []HARNameValue{}
+	for name, vs := range values {
+		for _, v := range vs {
+			pairs = append(pairs, HARNameValue{Name: name, Value: v})
+		}
+	}
+
+	slices.SortStableFunc(pairs, func(a, b HARNameValue) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return pairs
+}
+
+func (r *HARRecorder) matches(names []string, name string) bool {
+	return slices.ContainsFunc(names, func(n string) bool {
+		return strings.EqualFold(n, name)
+	})
+}
+
+func (r *HARRecorder) redactBody(body []byte) string {
+	if len(r.redaction.BodyFields) == 0 {
+		return string(body)
+	}
+
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		return string(body)
+	}
+
+	redacted, err := json.Marshal(r.redactValue(value))
+	if err != nil {
+		return string(body)
+	}
+
+	return string(redacted)
+}
+
+func (r *HARRecorder) redactValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, inner := range v {
+			if r.matches(r.redaction.BodyFields, key) {
+				v[key] = harRedacted
+				continue
+			}
+
+			v[key] = r.redactValue(inner)
+		}
+	case []any:
+		for i, inner := range v {
+			v[i] = r.redactValue(inner)
+		}
+	case string:
+		// Protected payloads nest JSON documents inside string fields
+		var nested any
+		if strings.HasPrefix(v, "{") && json.Unmarshal([]byte(v), &nested) == nil {
+			redacted, err := json.Marshal(r.redactValue(nested))
+			if err == nil {
+				return string(redacted)
+			}
+		}
+	}
+
+	return value
+}
+
+func readBody(body io.ReadCloser) ([]byte, io.ReadCloser, error) {
+	if body == nil || body == http.NoBody {
+		return nil, body, nil
+	}
+	defer body.Close()
+
+	contents, err := io.ReadAll(body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return contents, io.NopCloser(bytes.NewReader(contents)), nil
+}
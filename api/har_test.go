@@ -0,0 +1,68 @@
+package api
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	http "github.com/saucesteals/fhttp"
+	"github.com/saucesteals/fhttp/httptest"
+)
+
+func TestHARRecorderReleasesRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"ok":true}`)
+	}))
+	defer server.Close()
+
+	har := NewHARRecorder(HARRedaction{})
+	a := &API{Options: Options{HAR: har}, client: server.Client()}
+
+	send := func() *http.Request {
+		req, err := http.NewRequest(http.MethodPost, server.URL, strings.NewReader("sealed"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		har.AnnotateRequest(req, []byte("cleartext"))
+		return req
+	}
+
+	// Requests are released once their response body is closed
+	req := send()
+	res, err := a.Do(req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	har.AnnotateResponse(req, []byte("decrypted"))
+	res.Body.Close()
+
+	// Held requests are kept for annotations made after the body is closed
+	held := send()
+	har.Hold(held)
+	res, err = a.Do(held)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	res.Body.Close()
+	har.AnnotateResponse(held, []byte("decrypted"))
+	har.Done(held)
+
+	if len(har.pending) != 0 || len(har.cleartext) != 0 || len(har.held) != 0 {
+		t.Errorf("recorder still tracks %d entries, %d cleartexts and %d held requests", len(har.pending), len(har.cleartext), len(har.held))
+	}
+
+	entries := har.HAR().Log.Entries
+	if len(entries) != 2 {
+		t.Fatalf("recorded %d entries, want 2", len(entries))
+	}
+
+	for i, entry := range entries {
+		if entry.Response.Content.Comment != "cleartext: decrypted" {
+			t.Errorf("entry %d response comment = %q, want the decrypted body", i, entry.Response.Content.Comment)
+		}
+
+		if entry.Request.PostData == nil || entry.Request.PostData.Comment != "cleartext: cleartext" {
+			t.Errorf("entry %d request was not annotated", i)
+		}
+	}
+}
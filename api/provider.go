@@ -0,0 +1,93 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrUnsupportedRequest = errors.New("unsupported token request")
+	// ErrNotCreated is wrapped by providers failing before the token is
+	// requested, so another provider can safely be tried
+	ErrNotCreated = errors.New("token not created")
+)
+
+type TokenMode string
+
+var (
+	TokenModeWeb       TokenMode = "web"
+	TokenModeExtension TokenMode = "extension"
+)
+
+// Merchant identifies the merchant a token is bound to. Providers that can look
+// merchants up accept a URL alone.
+type Merchant struct {
+	MdxID    string
+	MdxURLID string
+	Name     string
+	URL      string
+}
+
+func (m Merchant) resolved() bool {
+	return m.MdxID != ""
+}
+
+// TokenRequest describes a virtual card to create, independently of the
+// provider creating it.
+type TokenRequest struct {
+	CardReferenceID string
+	Name            string
+	// Merchant binds the token when set
+	Merchant   *Merchant
+	OneTimeUse bool
+	// Duration is one of ONE_MONTH, THREE_MONTHS, SIX_MONTHS or ONE_YEAR, or
+	// empty for no limit
+	Duration string
+}
+
+type TokenResult struct {
+	Token Token
+	Mode  TokenMode
+	// Merchant is the merchant the token was bound to, resolved if the request
+	// only had a URL
+	Merchant *Merchant
+}
+
+// Capabilities describe which TokenRequest fields a provider supports.
+type Capabilities struct {
+	MerchantBinding bool
+	// MerchantLookup resolves a Merchant with only a URL
+	MerchantLookup bool
+	// RequiresMerchant rejects unbound tokens
+	RequiresMerchant bool
+	OneTimeUse       bool
+	Duration         bool
+}
+
+// Check returns an error wrapping ErrUnsupportedRequest if req uses a feature
+// missing from c.
+func (c Capabilities) Check(req TokenRequest) error {
+	switch {
+	case req.Merchant == nil && c.RequiresMerchant:
+		return fmt.Errorf("%w: merchant is required", ErrUnsupportedRequest)
+	case req.Merchant != nil && !c.MerchantBinding:
+		return fmt.Errorf("%w: merchant binding", ErrUnsupportedRequest)
+	case req.Merchant != nil && !req.Merchant.resolved() && !c.MerchantLookup:
+		return fmt.Errorf("%w: merchant lookup by URL", ErrUnsupportedRequest)
+	case req.OneTimeUse && !c.OneTimeUse:
+		return fmt.Errorf("%w: one-time use", ErrUnsupportedRequest)
+	case req.Duration != "" && !c.Duration:
+		return fmt.Errorf("%w: duration", ErrUnsupportedRequest)
+	}
+
+	return nil
+}
+
+// TokenProvider creates virtual cards, taking care of its own prerequisites
+// such as step-up challenges or merchant lookups.
+type TokenProvider interface {
+	Mode() TokenMode
+	Capabilities() Capabilities
+	Create(ctx context.Context, req TokenRequest) (TokenResult, error)
+}
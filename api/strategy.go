@@ -0,0 +1,191 @@
+package api
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+var (
+	TokenModeAuto TokenMode = "auto"
+)
+
+// Strategy is a TokenProvider that picks the best suited provider for each
+// request and fails over to the others when it is rate limited or failed
+// before requesting the token. Other errors are returned as they are, as the
+// token may have been created or a challenge used up.
+//
+// Merchant-bound requests prefer providers that require a merchant (the
+// extension) and unbound requests are left to the others (web). A rate
+// limited provider is skipped until Cooldown has passed.
+type Strategy struct {
+	providers []TokenProvider
+
+	// Cooldown is how long a rate limited provider is skipped, 2 minutes by
+	// default
+	Cooldown time.Duration
+
+	mu        sync.Mutex
+	throttled map[TokenMode]time.Time
+	failures  map[TokenMode]int
+}
+
+var _ TokenProvider = (*Strategy)(nil)
+
+func NewStrategy(providers ...TokenProvider) *Strategy {
+	return &Strategy{
+		providers: providers,
+		Cooldown:  time.Minute * 2,
+		throttled: map[TokenMode]time.Time{},
+		failures:  map[TokenMode]int{},
+	}
+}
+
+func (s *Strategy) Mode() TokenMode {
+	return TokenModeAuto
+}
+
+// Capabilities is the union of the capabilities of every provider. A request
+// may still be rejected if no single provider supports all of its features.
+func (s *Strategy) Capabilities() Capabilities {
+	var c Capabilities
+	requiresMerchant := len(s.providers) > 0
+	for _, provider := range s.providers {
+		pc := provider.Capabilities()
+		c.MerchantBinding = c.MerchantBinding || pc.MerchantBinding
+		c.MerchantLookup = c.MerchantLookup || pc.MerchantLookup
+		c.OneTimeUse = c.OneTimeUse || pc.OneTimeUse
+		c.Duration = c.Duration || pc.Duration
+		requiresMerchant = requiresMerchant && pc.RequiresMerchant
+	}
+	c.RequiresMerchant = requiresMerchant
+
+	return c
+}
+
+// ThrottledUntil returns when a rate limited provider will be tried again, or
+// the zero time if it is not throttled.
+func (s *Strategy) ThrottledUntil(mode TokenMode) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if until := s.throttled[mode]; time.Now().Before(until) {
+		return until
+	}
+
+	return time.Time{}
+}
+
+// Modes returns the modes of the providers able to handle req. A single mode
+// means the strategy cannot fail over for req.
+func (s *Strategy) Modes(req TokenRequest) []TokenMode {
+	modes := []TokenMode{}
+	for _, provider := range s.providers {
+		if provider.Capabilities().Check(req) == nil {
+			modes = append(modes, provider.Mode())
+		}
+	}
+
+	return modes
+}
+
+// candidates returns the providers able to handle req, best first.
+func (s *Strategy) candidates(req TokenRequest) []TokenProvider {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	candidates := []TokenProvider{}
+	for _, provider := range s.providers {
+		if provider.Capabilities().Check(req) == nil {
+			candidates = append(candidates, provider)
+		}
+	}
+
+	preferred := func(provider TokenProvider) int {
+		if provider.Capabilities().RequiresMerchant == (req.Merchant != nil) {
+			return 0
+		}
+		return 1
+	}
+
+	throttled := func(provider TokenProvider) int {
+		if now.Before(s.throttled[provider.Mode()]) {
+			return 1
+		}
+		return 0
+	}
+
+	slices.SortStableFunc(candidates, func(a, b TokenProvider) int {
+		return cmp.Or(
+			cmp.Compare(throttled(a), throttled(b)),
+			cmp.Compare(preferred(a), preferred(b)),
+			cmp.Compare(s.failures[a.Mode()], s.failures[b.Mode()]),
+		)
+	})
+
+	return candidates
+}
+
+func (s *Strategy) record(mode TokenMode, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case err == nil:
+		s.failures[mode] = 0
+	case errors.Is(err, ErrRateLimited):
+		s.throttled[mode] = time.Now().Add(s.Cooldown)
+	default:
+		s.failures[mode]++
+	}
+}
+
+// Create tries each suitable provider that is not throttled in turn, moving on
+// only after errors wrapping ErrRateLimited, ErrNotCreated or
+// ErrUnsupportedRequest. The result reports which provider created the token.
+// If every suitable provider is throttled the error wraps ErrRateLimited.
+func (s *Strategy) Create(ctx context.Context, req TokenRequest) (TokenResult, error) {
+	candidates := s.candidates(req)
+	if len(candidates) == 0 {
+		return TokenResult{}, fmt.Errorf("%w: no mode supports every requested feature", ErrUnsupportedRequest)
+	}
+
+	errs := []error{}
+	for _, provider := range candidates {
+		if err := ctx.Err(); err != nil {
+			return TokenResult{}, err
+		}
+
+		if until := s.ThrottledUntil(provider.Mode()); !until.IsZero() {
+			errs = append(errs, fmt.Errorf("%s: %w until %s", provider.Mode(), ErrRateLimited, until.Format(time.TimeOnly)))
+			continue
+		}
+
+		result, err := provider.Create(ctx, req)
+		s.record(provider.Mode(), err)
+		if err == nil {
+			return result, nil
+		}
+
+		errs = append(errs, fmt.Errorf("%s: %w", provider.Mode(), err))
+		if !failover(ctx, err) {
+			break
+		}
+	}
+
+	return TokenResult{}, errors.Join(errs...)
+}
+
+// failover reports whether err leaves it safe to try another provider.
+func failover(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	return errors.Is(err, ErrRateLimited) || errors.Is(err, ErrNotCreated) || errors.Is(err, ErrUnsupportedRequest)
+}
@@ -0,0 +1,223 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+// fakeProvider creates tokens named after its mode, failing with the next of
+// errs on each call until they run out.
+type fakeProvider struct {
+	mode  TokenMode
+	caps  Capabilities
+	errs  []error
+	calls int
+}
+
+func (p *fakeProvider) Mode() TokenMode {
+	return p.mode
+}
+
+func (p *fakeProvider) Capabilities() Capabilities {
+	return p.caps
+}
+
+func (p *fakeProvider) Create(ctx context.Context, req TokenRequest) (TokenResult, error) {
+	p.calls++
+	if len(p.errs) > 0 {
+		err := p.errs[0]
+		p.errs = p.errs[1:]
+		if err != nil {
+			return TokenResult{}, err
+		}
+	}
+
+	return TokenResult{Token: Token{TokenName: req.Name}, Mode: p.mode}, nil
+}
+
+func newFakeWeb(errs ...error) *fakeProvider {
+	return &fakeProvider{
+		mode: TokenModeWeb,
+		caps: Capabilities{MerchantBinding: true, OneTimeUse: true, Duration: true},
+		errs: errs,
+	}
+}
+
+func newFakeExtension(errs ...error) *fakeProvider {
+	return &fakeProvider{
+		mode: TokenModeExtension,
+		caps: Capabilities{MerchantBinding: true, MerchantLookup: true, RequiresMerchant: true},
+		errs: errs,
+	}
+}
+
+var (
+	unbound       = TokenRequest{Name: "card"}
+	bound         = TokenRequest{Name: "card", Merchant: &Merchant{MdxID: "1", Name: "Netflix"}}
+	byURL         = TokenRequest{Name: "card", Merchant: &Merchant{URL: "www.netflix.com"}}
+	oneTime       = TokenRequest{Name: "card", Merchant: &Merchant{MdxID: "1"}, OneTimeUse: true}
+	tooMuch       = TokenRequest{Name: "card", Merchant: &Merchant{URL: "www.netflix.com"}, OneTimeUse: true}
+	errOther      = errors.New("server error")
+	errNotCreated = fmt.Errorf("%w: merchant lookup failed", ErrNotCreated)
+)
+
+func TestStrategyModes(t *testing.T) {
+	tests := []struct {
+		name string
+		req  TokenRequest
+		want []TokenMode
+	}{
+		{"unbound", unbound, []TokenMode{TokenModeWeb}},
+		{"bound", bound, []TokenMode{TokenModeWeb, TokenModeExtension}},
+		{"merchant url", byURL, []TokenMode{TokenModeExtension}},
+		{"one-time use", oneTime, []TokenMode{TokenModeWeb}},
+		{"unsupported", tooMuch, []TokenMode{}},
+	}
+
+	s := NewStrategy(newFakeWeb(), newFakeExtension())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.Modes(tt.req); !slices.Equal(got, tt.want) {
+				t.Errorf("Modes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStrategyCreate(t *testing.T) {
+	tests := []struct {
+		name      string
+		web       *fakeProvider
+		extension *fakeProvider
+		req       TokenRequest
+		wantMode  TokenMode
+		wantErr   error
+		wantCalls [2]int
+	}{
+		{
+			name:      "unbound uses web",
+			web:       newFakeWeb(),
+			extension: newFakeExtension(),
+			req:       unbound,
+			wantMode:  TokenModeWeb,
+			wantCalls: [2]int{1, 0},
+		},
+		{
+			name:      "bound prefers the extension",
+			web:       newFakeWeb(),
+			extension: newFakeExtension(),
+			req:       bound,
+			wantMode:  TokenModeExtension,
+			wantCalls: [2]int{0, 1},
+		},
+		{
+			name:      "fails over to web",
+			web:       newFakeWeb(),
+			extension: newFakeExtension(errNotCreated),
+			req:       bound,
+			wantMode:  TokenModeWeb,
+			wantCalls: [2]int{1, 1},
+		},
+		{
+			name:      "no failover after other errors",
+			web:       newFakeWeb(),
+			extension: newFakeExtension(errOther),
+			req:       bound,
+			wantErr:   errOther,
+			wantCalls: [2]int{0, 1},
+		},
+		{
+			name:      "fails over when rate limited",
+			web:       newFakeWeb(),
+			extension: newFakeExtension(ErrRateLimited),
+			req:       bound,
+			wantMode:  TokenModeWeb,
+			wantCalls: [2]int{1, 1},
+		},
+		{
+			name:      "no failover for unbound",
+			web:       newFakeWeb(errOther),
+			extension: newFakeExtension(),
+			req:       unbound,
+			wantErr:   errOther,
+			wantCalls: [2]int{1, 0},
+		},
+		{
+			name:      "unsupported",
+			web:       newFakeWeb(),
+			extension: newFakeExtension(),
+			req:       tooMuch,
+			wantErr:   ErrUnsupportedRequest,
+			wantCalls: [2]int{0, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewStrategy(tt.web, tt.extension)
+
+			result, err := s.Create(context.Background(), tt.req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Create() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if result.Mode != tt.wantMode {
+				t.Errorf("Create() mode = %q, want %q", result.Mode, tt.wantMode)
+			}
+
+			if calls := [2]int{tt.web.calls, tt.extension.calls}; calls != tt.wantCalls {
+				t.Errorf("calls = %v, want %v", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestStrategyThrottle(t *testing.T) {
+	web := newFakeWeb(ErrRateLimited)
+	s := NewStrategy(web, newFakeExtension())
+
+	if _, err := s.Create(context.Background(), unbound); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("Create() error = %v, want %v", err, ErrRateLimited)
+	}
+
+	if until := s.ThrottledUntil(TokenModeWeb); until.IsZero() {
+		t.Fatal("web is not throttled after being rate limited")
+	}
+
+	// Throttled providers are skipped without being called
+	if _, err := s.Create(context.Background(), unbound); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("Create() error = %v, want %v", err, ErrRateLimited)
+	}
+	if web.calls != 1 {
+		t.Errorf("web calls = %d, want 1", web.calls)
+	}
+
+	s.Cooldown = 0
+	s.throttled[TokenModeWeb] = time.Now().Add(-time.Second)
+	if _, err := s.Create(context.Background(), unbound); err != nil {
+		t.Fatalf("Create() after cooldown error = %v", err)
+	}
+}
+
+func TestStrategyPrefersHealthyProvider(t *testing.T) {
+	first := newFakeWeb(errNotCreated)
+	second := newFakeWeb()
+	second.mode = "web2"
+	s := NewStrategy(first, second)
+
+	// Equally suited providers are ordered by their recent failures, so the
+	// next request goes to the second one first
+	for range 2 {
+		if result, err := s.Create(context.Background(), unbound); err != nil || result.Mode != second.mode {
+			t.Fatalf("Create() = %q, %v, want %q", result.Mode, err, second.mode)
+		}
+	}
+
+	if first.calls != 1 {
+		t.Errorf("first calls = %d, want 1", first.calls)
+	}
+}
@@ -0,0 +1,323 @@
+// Package eno creates and manages Capital One virtual cards.
+//
+// Client owns the login lifecycle (device identity, saved cookies, OTP
+// challenge, card registration and express enrollment) and exposes the token
+// operations of the web and extension APIs once logged in.
+package eno
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"log/slog"
+
+	http "github.com/saucesteals/fhttp"
+
+	"github.com/saucesteals/eno/api"
+	"github.com/saucesteals/eno/extension"
+	"github.com/saucesteals/eno/web"
+)
+
+type Options struct {
+	Credentials api.Credentials
+	Storage     Storage
+	Prompter    Prompter
+
+	BrowserBinary       string
+	BrowserUserDataPath string
+
+	// Logger defaults to discarding everything
+	Logger *slog.Logger
+	HAR    *api.HARRecorder
+
+	// MinRemainingAttempts stops retrying wrong OTP codes once this many
+	// attempts remain
+	MinRemainingAttempts int
+	// OTPMaxFailures is how many wrong login codes lock the profile, which
+	// the login challenge does not report. Wrong login codes are not retried
+	// when zero
+	OTPMaxFailures int
+}
+
+type Client struct {
+	API       *api.API
+	Web       *web.Web
+	Extension *extension.Extension
+
+	storage  Storage
+	prompter Prompter
+	log      *slog.Logger
+	minOTP   int
+	maxOTP   int
+
+	// The providers are built once so the strategy keeps its rate limit and
+	// failure state, and the web provider its step-ups, across requests
+	webProvider *web.Provider
+	strategy    *api.Strategy
+}
+
+// New restores the profile kept in opts.Storage, generating a new device if
+// there is none. Call Login before using the token operations.
+func New(opts Options) (*Client, error) {
+	if opts.Storage == nil {
+		return nil, errors.New("storage is required")
+	}
+
+	if opts.Prompter == nil {
+		return nil, ErrNoPrompter
+	}
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+
+	capApi, err := api.New(api.Options{
+		Logger:              logger,
+		Credentials:         opts.Credentials,
+		BrowserUserDataPath: opts.BrowserUserDataPath,
+		BrowserBinary:       opts.BrowserBinary,
+		HAR:                 opts.HAR,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("new api: %w", err)
+	}
+
+	var device extension.Device
+	if err := opts.Storage.Load(StorageDevice, &device); err != nil {
+		if !errors.Is(err, ErrNotFound) {
+			return nil, fmt.Errorf("load device: %w", err)
+		}
+
+		device = extension.GenerateDevice()
+		if err := opts.Storage.Save(StorageDevice, device); err != nil {
+			return nil, fmt.Errorf("save device: %w", err)
+		}
+	}
+
+	cookies := []*http.Cookie{}
+	if err := opts.Storage.Load(StorageCookies, &cookies); err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("load cookies: %w", err)
+	}
+	capApi.SetCookies(cookies)
+
+	capWeb := web.New(capApi)
+
+	stepUps := []web.StepUpAuthorization{}
+	if err := opts.Storage.Load(StorageStepUps, &stepUps); err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("load step-ups: %w", err)
+	}
+	capWeb.SetStepUpAuthorizations(stepUps)
+
+	capExt, err := extension.New(capApi, device)
+	if err != nil {
+		return nil, fmt.Errorf("new extension: %w", err)
+	}
+
+	client := &Client{
+		API:       capApi,
+		Web:       capWeb,
+		Extension: capExt,
+		storage:   opts.Storage,
+		prompter:  opts.Prompter,
+		log:       logger,
+		minOTP:    opts.MinRemainingAttempts,
+		maxOTP:    opts.OTPMaxFailures,
+	}
+	client.webProvider = capWeb.Provider(client.StepUpOptions())
+	client.strategy = api.NewStrategy(client.webProvider, capExt)
+
+	return client, nil
+}
+
+// Login reuses the saved session if it is still valid, logging in through the
+// browser otherwise. New devices complete the OTP challenge, register every
+// card with its CVV and enroll in express checkout. The session is saved on
+// success.
+func (c *Client) Login(ctx context.Context) error {
+	var express extension.ExpressEnrollment
+	if err := c.storage.Load(StorageExpress, &express); err != nil && !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("load express: %w", err)
+	}
+
+	for {
+		session, err := c.Extension.GetSession(ctx)
+		if err != nil {
+			err = c.API.Login(ctx)
+			if err != nil {
+				return fmt.Errorf("login: %w", err)
+			}
+
+			session, err = c.Extension.GetSession(ctx)
+			if err != nil {
+				return fmt.Errorf("get session: %w", err)
+			}
+
+			c.log.Info("Logged in with new session")
+		} else {
+			c.log.Info("Loaded saved session")
+		}
+
+		if session.LoginStatus == extension.LoginStatusSuccess && express.ExpressCheckoutToken != "" {
+			break
+		}
+
+		c.log.Info("Login status", "status", session.LoginStatus)
+		if session.LoginStatus == extension.LoginStatusChallenge {
+			if err := c.challenge(ctx); err != nil {
+				return err
+			}
+		}
+
+		if express.ExpressCheckoutToken == "" {
+			enrollement, err := c.Extension.ExpressEnroll(ctx)
+			if err != nil {
+				return fmt.Errorf("enroll in express: %w", err)
+			}
+
+			express.ExpressCheckoutToken = enrollement.ExpressCheckoutToken
+		} else {
+			login, err := c.Extension.ExpressLogin(ctx, express.ExpressCheckoutToken)
+			if err != nil {
+				return fmt.Errorf("login to express: %w", err)
+			}
+
+			express.ExpressCheckoutToken = login.ExpressCheckoutToken
+		}
+
+		if err := c.storage.Save(StorageExpress, express); err != nil {
+			return fmt.Errorf("save express: %w", err)
+		}
+	}
+
+	return c.Save()
+}
+
+// challenge completes the login OTP challenge and registers every card.
+func (c *Client) challenge(ctx context.Context) error {
+	result, err := c.Extension.OTPChallenge(ctx, extension.OTPChallengeOptions{
+		Provider:             c.prompter,
+		Select:               c.prompter.SelectDestination,
+		MinRemainingAttempts: c.minOTP,
+		MaxFailures:          c.maxOTP,
+	})
+	if err != nil {
+		return fmt.Errorf("otp challenge (profile %s, %d attempts remaining): %w", result.ProfileStatus, result.RemainingAttempts, err)
+	}
+
+	c.log.Info("Successfully validated OTP", "contactPoint", result.Destination, "attempts", result.Attempts)
+
+	cards, err := c.Extension.GetPaymentCards(ctx)
+	if err != nil {
+		return fmt.Errorf("get payment cards: %w", err)
+	}
+
+	for _, card := range cards {
+		cvv, err := c.prompter.CVV(ctx, card)
+		if err != nil {
+			return fmt.Errorf("cvv: %w", err)
+		}
+
+		err = c.Extension.ConfigureCard(ctx, card, cvv)
+		if err != nil {
+			return fmt.Errorf("configure card: %w", err)
+		}
+
+		err = c.Extension.CompleteCardRegister(ctx, card)
+		if err != nil {
+			return fmt.Errorf("complete card register: %w", err)
+		}
+
+		c.log.Info("Card configured", "card", card.CardNumber)
+	}
+
+	return nil
+}
+
+// Save persists the session cookies and step-up authorizations.
+func (c *Client) Save() error {
+	if err := c.storage.Save(StorageCookies, c.API.GetCookies()); err != nil {
+		return fmt.Errorf("save cookies: %w", err)
+	}
+
+	if err := c.storage.Save(StorageStepUps, c.Web.StepUpAuthorizations()); err != nil {
+		return fmt.Errorf("save step-ups: %w", err)
+	}
+
+	return nil
+}
+
+func (c *Client) StepUpOptions() web.StepUpOptions {
+	return web.StepUpOptions{
+		Provider:             c.prompter,
+		Select:               c.prompter.SelectDestination,
+		MinRemainingAttempts: c.minOTP,
+	}
+}
+
+// StepUp completes the step-up challenge required by web operations on card,
+// reusing a saved authorization when possible, and saves it.
+func (c *Client) StepUp(ctx context.Context, card extension.PaymentCard) (web.StepUpResult, error) {
+	result, err := c.Web.StepUp(ctx, card, c.StepUpOptions())
+	if err != nil {
+		return result, err
+	}
+
+	if result.Required && !result.Reused {
+		if err := c.Save(); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+func (c *Client) Cards(ctx context.Context) ([]extension.PaymentCard, error) {
+	return c.Extension.GetPaymentCards(ctx)
+}
+
+// Provider returns the TokenProvider for mode: web, extension or auto.
+func (c *Client) Provider(mode api.TokenMode) (api.TokenProvider, error) {
+	switch mode {
+	case api.TokenModeWeb:
+		return c.webProvider, nil
+	case api.TokenModeExtension:
+		return c.Extension, nil
+	case api.TokenModeAuto:
+		return c.strategy, nil
+	default:
+		return nil, fmt.Errorf("unknown mode: %s", mode)
+	}
+}
+
+// CreateToken creates a card with the provider for mode.
+func (c *Client) CreateToken(ctx context.Context, mode api.TokenMode, req api.TokenRequest) (api.TokenResult, error) {
+	provider, err := c.Provider(mode)
+	if err != nil {
+		return api.TokenResult{}, err
+	}
+
+	result, err := provider.Create(ctx, req)
+	if err != nil {
+		return result, err
+	}
+
+	return result, c.Save()
+}
+
+func (c *Client) Tokens(ctx context.Context, card extension.PaymentCard, query web.TokenQuery) iter.Seq2[web.ListedToken, error] {
+	return c.Web.Tokens(ctx, card, query)
+}
+
+func (c *Client) DeleteToken(ctx context.Context, card extension.PaymentCard, token web.ListedToken) error {
+	return c.Web.DeleteToken(ctx, card, token)
+}
+
+func (c *Client) SetTokenAuthorizations(ctx context.Context, card extension.PaymentCard, token web.ListedToken, allow bool) error {
+	return c.Web.SetTokenAuthorizations(ctx, card, token, allow)
+}
+
+func (c *Client) RenameToken(ctx context.Context, card extension.PaymentCard, token web.ListedToken, name string) error {
+	return c.Web.RenameToken(ctx, card, token, name)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// splitArgs splits a command line on whitespace, keeping single or double
+// quoted sections together.
+func splitArgs(line string) ([]string, error) {
+	args := []string{}
+	var current strings.Builder
+	var quote rune
+	inArg := false
+
+	for _, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+				continue
+			}
+			current.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+
+	if inArg {
+		args = append(args, current.String())
+	}
+
+	return args, nil
+}
+
+// parseFlags parses args into fs. ok is false when only help was requested.
+func parseFlags(fs *flag.FlagSet, args []string) (ok bool, err error) {
+	err = fs.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if fs.NArg() > 0 {
+		return false, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+
+	return true, nil
+}
+
+// parseDate accepts a date (2006-01-02) in local time or an RFC 3339
+// timestamp.
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
+
+func splitList(value string) []string {
+	parts := []string{}
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	return parts
+}
+
+// parseArgs parses args into fs like parseFlags, but allows flags and
+// positional arguments to be mixed and returns the positional arguments.
+func parseArgs(fs *flag.FlagSet, args []string) (positional []string, ok bool, err error) {
+	positional = []string{}
+	for {
+		err = fs.Parse(args)
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, false, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+
+		if fs.NArg() == 0 {
+			return positional, true, nil
+		}
+
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/saucesteals/eno/profile"
+)
+
+// browserCaches are rebuilt by the browser and left out of bundles.
+var browserCaches = []string{
+	"Cache",
+	"Code Cache",
+	"GPUCache",
+	"DawnCache",
+	"DawnGraphiteCache",
+	"DawnWebGPUCache",
+	"GraphiteDawnCache",
+	"GrShaderCache",
+	"ShaderCache",
+	"CacheStorage",
+	"ScriptCache",
+	"component_crx_cache",
+	"Crashpad",
+	"SingletonCookie",
+	"SingletonLock",
+	"SingletonSocket",
+}
+
+// bundlePassphrase reads the passphrase from ENO_PROFILE_PASSPHRASE or asks
+// for it, twice when exporting.
+func bundlePassphrase(confirm bool) (string, error) {
+	if passphrase := os.Getenv("ENO_PROFILE_PASSPHRASE"); passphrase != "" {
+		return passphrase, nil
+	}
+
+	passphrase := ask("Enter bundle passphrase")
+	if passphrase == "" {
+		return "", errors.New("a passphrase is required")
+	}
+
+	if confirm && ask("Confirm bundle passphrase") != passphrase {
+		return "", errors.New("passphrases do not match")
+	}
+
+	return passphrase, nil
+}
+
+func exportProfile(cfg *Config, args []string) error {
+	fs := flag.NewFlagSet("profile export", flag.ContinueOnError)
+	output := fs.String("output", "", "write the bundle to `path` (default <name>.enoprofile)")
+	cards := fs.Bool("cards", false, "include card exports")
+	credentials := fs.Bool("credentials", false, "include the saved username and password")
+	stepUps := fs.Bool("step-ups", false, "include saved step-ups and their cookies")
+
+	names, ok, err := parseArgs(fs, args)
+	if !ok {
+		return err
+	}
+
+	if len(names) != 1 {
+		return errors.New("usage: eno profile export <name> [--output path] [--cards] [--credentials] [--step-ups]")
+	}
+
+	dir, err := findProfile(names[0])
+	if err != nil {
+		return err
+	}
+
+	p, err := profile.Open(dir)
+	if err != nil {
+		return err
+	}
+
+	documents, err := p.Store.List()
+	if err != nil {
+		return err
+	}
+
+	// Secrets are only bundled when asked for
+	excluded := map[string]bool{
+		profile.Credentials: !*credentials,
+		profile.StepUps:     !*stepUps,
+	}
+	documents = slices.DeleteFunc(documents, func(name string) bool {
+		return excluded[name]
+	})
+
+	settings, err := cfg.For(names[0])
+	if err != nil {
+		return err
+	}
+
+	directories := []string{"user_data"}
+	if *cards {
+		if settings.ExportDir != "" {
+			return fmt.Errorf("--cards only bundles card exports kept in the profile, not in %s", settings.ExportDir)
+		}
+
+		directories = append(directories, "cards")
+	}
+
+	passphrase, err := bundlePassphrase(true)
+	if err != nil {
+		return err
+	}
+
+	file := *output
+	if file == "" {
+		file = names[0] + ".enoprofile"
+	}
+
+	// The bundle is streamed to a temporary file so a failed export does not
+	// leave a partial bundle behind
+	f, err := os.OpenFile(file+".tmp", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	manifest, err := profile.Export(f, p, passphrase, profile.ExportOptions{
+		Documents:   documents,
+		Directories: directories,
+		Skip: func(rel string) bool {
+			return slices.Contains(browserCaches, path.Base(rel))
+		},
+	})
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("export: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(f.Name(), file); err != nil {
+		return err
+	}
+
+	log.Info("Exported profile", "profile", names[0], "documents", strings.Join(manifest.Documents(), ","), "files", manifest.Files(), "path", file)
+	return nil
+}
+
+func importProfile(args []string) error {
+	fs := flag.NewFlagSet("profile import", flag.ContinueOnError)
+	name := fs.String("name", "", "import as `profile` (default the exported profile's name)")
+	force := fs.Bool("force", false, "overwrite an existing profile")
+
+	paths, ok, err := parseArgs(fs, args)
+	if !ok {
+		return err
+	}
+
+	if len(paths) != 1 {
+		return errors.New("usage: eno profile import <bundle> [--name profile] [--force]")
+	}
+
+	f, err := os.Open(paths[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	passphrase, err := bundlePassphrase(false)
+	if err != nil {
+		return err
+	}
+
+	bundle, err := profile.ReadBundle(f, passphrase)
+	if err != nil {
+		return err
+	}
+	defer bundle.Close()
+
+	target := *name
+	if target == "" {
+		target = bundle.Manifest.Profile
+	}
+
+	if err := validateProfileName(target); err != nil {
+		return err
+	}
+
+	// Profiles without credentials log in with their name as the username
+	if target != bundle.Manifest.Profile && !slices.Contains(bundle.Manifest.Documents(), profile.Credentials) {
+		return fmt.Errorf("bundles without credentials can only be imported as %s, export it with --credentials to use --name", bundle.Manifest.Profile)
+	}
+
+	_, err = findProfile(target)
+	exists := err == nil
+	if exists && !*force {
+		return fmt.Errorf("profile %s already exists, use --force to overwrite it or --name to import it under another name", target)
+	}
+
+	dir, err := GetProgramDir("profiles", target)
+	if err != nil {
+		return err
+	}
+
+	p, err := profile.Open(dir)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		if err := clearProfile(p); err != nil {
+			return fmt.Errorf("clear profile: %w", err)
+		}
+	}
+
+	if err := bundle.Restore(p); err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+
+	log.Info("Imported profile", "profile", target, "exported", bundle.Manifest.CreatedAt.Local().Format("2006-01-02 15:04"), "documents", strings.Join(bundle.Manifest.Documents(), ","), "files", bundle.Manifest.Files(), "path", dir)
+	return nil
+}
+
+// clearProfile removes what an import replaces, so nothing of the overwritten
+// profile is mixed with the bundle. Credentials are kept for bundles
+// exported without them.
+func clearProfile(p *profile.Profile) error {
+	for _, dir := range []string{"user_data", "cards"} {
+		if err := os.RemoveAll(filepath.Join(p.Dir, dir)); err != nil {
+			return err
+		}
+	}
+
+	documents, err := p.Store.List()
+	if err != nil {
+		return err
+	}
+
+	for _, document := range documents {
+		if document == profile.Credentials {
+			continue
+		}
+
+		if err := p.Delete(document); err != nil {
+			return fmt.Errorf("delete %s: %w", document, err)
+		}
+	}
+
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -23,15 +24,35 @@ func cleanName(name string) string {
 	)
 }
 
+// exportRoot returns the directory holding the card exports of a profile,
+// <export_dir>/<profile> if an export directory is configured.
+func exportRoot(name string, dir string) string {
+	if config.ExportDir != "" {
+		return filepath.Join(config.ExportDir, name)
+	}
+
+	return filepath.Join(dir, "cards")
+}
+
+func getCardDirectory(profile *Profile, card extension.PaymentCard) (string, error) {
+	dir := filepath.Join(exportRoot(profile.Name, profile.Dir), cleanName(card.ProductDescription))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+
+	return dir, nil
+}
+
 func NewCardWriter(profile *Profile, card extension.PaymentCard, suffix string) (*CardWriter, error) {
-	dir, err := profile.GetDirectory(
-		"cards",
-		cleanName(card.ProductDescription),
-	)
+	dir, err := getCardDirectory(profile, card)
 	if err != nil {
 		return nil, err
 	}
 
+	return newCardWriter(dir, suffix)
+}
+
+func newCardWriter(dir string, suffix string) (*CardWriter, error) {
 	t := time.Now().Format("2006_01_02_15_04_05")
 	fileName := path.Join(dir, fmt.Sprintf("%s_%s.csv", t, cleanName(suffix)))
 	f, err := os.OpenFile(fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
@@ -46,6 +67,12 @@ func (w *CardWriter) GetPath() string {
 	return w.path
 }
 
+// GetBatch identifies the run that created the cards, the file name without
+// its extension.
+func (w *CardWriter) GetBatch() string {
+	return strings.TrimSuffix(path.Base(w.path), path.Ext(w.path))
+}
+
 func (w *CardWriter) Write(card api.Token) error {
 	expirationParts := strings.Split(card.ExpirationDate, "/")
 	if len(expirationParts) != 2 {
@@ -74,3 +101,93 @@ func (w *CardWriter) Write(card api.Token) error {
 func (w *CardWriter) Close() error {
 	return w.f.Close()
 }
+
+// ExportedCard is a card read back from a file written by CardWriter.
+type ExportedCard struct {
+	Path     string
+	Number   string
+	ExpMonth int
+	ExpYear  int
+	Cvv      string
+}
+
+func (c ExportedCard) LastFour() string {
+	if len(c.Number) < 4 {
+		return c.Number
+	}
+
+	return c.Number[len(c.Number)-4:]
+}
+
+func (c ExportedCard) Token() api.Token {
+	return api.Token{
+		Token:          c.Number,
+		Cvv:            c.Cvv,
+		ExpirationDate: fmt.Sprintf("%02d/%d", c.ExpMonth, c.ExpYear),
+		LastFour:       c.LastFour(),
+	}
+}
+
+// ReadCardExports reads every card exported for card, skipping duplicates.
+func ReadCardExports(profile *Profile, card extension.PaymentCard) ([]ExportedCard, error) {
+	dir, err := getCardDirectory(profile, card)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	cards := []ExportedCard{}
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".csv" {
+			continue
+		}
+
+		fileName := path.Join(dir, entry.Name())
+		contents, err := os.ReadFile(fileName)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, line := range strings.Split(string(contents), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" {
+				continue
+			}
+
+			parts := strings.Split(line, ",")
+			if len(parts) != 4 {
+				return nil, fmt.Errorf("%s:%d: invalid card", fileName, i+1)
+			}
+
+			expMonth, err := strconv.Atoi(parts[1])
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: invalid expiration month", fileName, i+1)
+			}
+
+			expYear, err := strconv.Atoi(parts[2])
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: invalid expiration year", fileName, i+1)
+			}
+
+			if seen[parts[0]] {
+				continue
+			}
+			seen[parts[0]] = true
+
+			cards = append(cards, ExportedCard{
+				Path:     fileName,
+				Number:   parts[0],
+				ExpMonth: expMonth,
+				ExpYear:  expYear,
+				Cvv:      parts[3],
+			})
+		}
+	}
+
+	return cards, nil
+}
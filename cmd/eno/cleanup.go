@@ -0,0 +1,342 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/saucesteals/eno"
+	"github.com/saucesteals/eno/extension"
+	"github.com/saucesteals/eno/inventory"
+	"github.com/saucesteals/eno/web"
+)
+
+type CleanupAction string
+
+var (
+	CleanupActionDelete CleanupAction = "delete"
+	CleanupActionPause  CleanupAction = "pause"
+)
+
+// CleanupPolicy is a retention rule kept in the profile's policies.json, e.g.
+//
+//	{"name": "stale web cards", "action": "delete", "namePattern": "Web Card *", "minAgeDays": 45}
+type CleanupPolicy struct {
+	Name   string        `json:"name"`
+	Action CleanupAction `json:"action"`
+	// Card limits the policy to the card with this reference ID or last four
+	// digits, every card when empty
+	Card string `json:"card,omitempty"`
+
+	// NamePattern matches names with * and ? wildcards
+	NamePattern     string   `json:"namePattern,omitempty"`
+	Merchant        string   `json:"merchant,omitempty"`
+	Statuses        []string `json:"statuses,omitempty"`
+	DerivedStatuses []string `json:"derivedStatuses,omitempty"`
+	Expired         bool     `json:"expired,omitempty"`
+	MinAgeDays      int      `json:"minAgeDays,omitempty"`
+	// NotUpdatedDays matches cards that were not updated in as many days.
+	// Charges are not updates, so it only approximates cards going unused:
+	// a card charged every month but never edited still matches
+	NotUpdatedDays int `json:"notUpdatedDays,omitempty"`
+
+	// UnusedDays is the old name of NotUpdatedDays, rejected rather than
+	// ignored so a policy does not silently match more cards
+	UnusedDays int `json:"unusedDays,omitempty"`
+}
+
+func (p CleanupPolicy) appliesTo(card extension.PaymentCard) bool {
+	return p.Card == "" || p.Card == card.CardReferenceID || strings.HasSuffix(card.CardNumber, p.Card)
+}
+
+func (p CleanupPolicy) query(now time.Time) (web.TokenQuery, error) {
+	if p.Action != CleanupActionDelete && p.Action != CleanupActionPause {
+		return web.TokenQuery{}, fmt.Errorf("unknown action: %s", p.Action)
+	}
+
+	query := web.NewTokenQuery().Merchant(p.Merchant)
+
+	if p.NamePattern != "" {
+		pattern := regexp.QuoteMeta(p.NamePattern)
+		pattern = strings.ReplaceAll(pattern, `\*`, ".*")
+		pattern = strings.ReplaceAll(pattern, `\?`, ".")
+
+		re, err := regexp.Compile("^" + pattern + "$")
+		if err != nil {
+			return web.TokenQuery{}, fmt.Errorf("invalid name pattern: %w", err)
+		}
+		query = query.NamePattern(re)
+	}
+
+	statuses := p.Statuses
+	if p.Action == CleanupActionPause && len(statuses) == 0 {
+		statuses = []string{string(web.TokenStatusActive)}
+	}
+
+	for _, status := range statuses {
+		query = query.Status(web.TokenStatus(strings.ToUpper(status)))
+	}
+
+	if len(p.DerivedStatuses) > 0 {
+		query = query.DerivedStatus(p.DerivedStatuses...)
+	}
+
+	if p.Expired {
+		query = query.Expired(true)
+	}
+
+	if p.MinAgeDays > 0 {
+		query = query.CreatedBetween(time.Time{}, now.AddDate(0, 0, -p.MinAgeDays))
+	}
+
+	if p.UnusedDays > 0 {
+		return web.TokenQuery{}, fmt.Errorf("unusedDays was renamed to notUpdatedDays")
+	}
+
+	if p.NotUpdatedDays > 0 {
+		query = query.UpdatedBetween(time.Time{}, now.AddDate(0, 0, -p.NotUpdatedDays))
+	}
+
+	return query, nil
+}
+
+// AuditEntry records a single action taken by a cleanup policy. Entries are
+// appended to the profile's audit.jsonl.
+type AuditEntry struct {
+	Time             time.Time     `json:"time"`
+	Policy           string        `json:"policy"`
+	Action           CleanupAction `json:"action"`
+	CardReferenceID  string        `json:"cardReferenceId"`
+	TokenReferenceID string        `json:"tokenReferenceId"`
+	TokenName        string        `json:"tokenName"`
+	TokenLastFour    string        `json:"tokenLastFour"`
+	DryRun           bool          `json:"dryRun"`
+	Error            string        `json:"error,omitempty"`
+}
+
+func appendAudit(profile *Profile, entries ...AuditEntry) error {
+	f, err := os.OpenFile(filepath.Join(profile.Dir, "audit.jsonl"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	encoder := json.NewEncoder(f)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func cleanup(ctx context.Context, profile *Profile, client *eno.Client, cards []extension.PaymentCard, args []string) error {
+	capWeb := client.Web
+
+	fs := flag.NewFlagSet("cleanup", flag.ContinueOnError)
+	policyNames := fs.String("policy", "", "comma separated `names` of the policies to run (default all)")
+	dryRun := fs.Bool("dry-run", false, "log matching cards without changing them")
+	yes := fs.Bool("yes", false, "apply policies without asking for confirmation")
+	every := fs.Duration("every", 0, "keep running, evaluating the policies at this `interval`")
+	concurrency := fs.Int("concurrency", 4, "number of cards updated at once")
+	retries := fs.Int("retries", 2, "number of times a failed update is retried")
+
+	if ok, err := parseFlags(fs, args); !ok {
+		return err
+	}
+
+	if *every > 0 && !*yes && !*dryRun {
+		return fmt.Errorf("--every requires --yes or --dry-run")
+	}
+
+	policies, err := profile.Policies.Get()
+	if err != nil {
+		if errors.Is(err, ErrResourceMissing) {
+			log.Info("No cleanup policies found", "path", filepath.Join(profile.Dir, "policies.json"))
+			return nil
+		}
+
+		return fmt.Errorf("get policies: %w", err)
+	}
+
+	if names := splitList(*policyNames); len(names) > 0 {
+		selected := []CleanupPolicy{}
+		for _, name := range names {
+			i := slices.IndexFunc(policies, func(p CleanupPolicy) bool { return p.Name == name })
+			if i < 0 {
+				return fmt.Errorf("unknown policy: %s", name)
+			}
+			selected = append(selected, policies[i])
+		}
+		policies = selected
+	}
+
+	opts := executeOptions{Concurrency: *concurrency, Retries: *retries}
+	for {
+		// The inventory is only held while the policies run so other eno
+		// processes can use the profile between runs
+		store, err := profile.OpenInventory()
+		if err != nil {
+			return fmt.Errorf("open inventory: %w", err)
+		}
+
+		failed, errored := 0, 0
+		for _, policy := range policies {
+			for _, card := range cards {
+				if !policy.appliesTo(card) {
+					continue
+				}
+
+				n, err := applyPolicy(ctx, profile, capWeb, store, card, policy, *dryRun, *yes, opts)
+				if err != nil {
+					errored++
+					log.Error("Failed to apply policy", "policy", policy.Name, "card", card.CardNumber, "error", err)
+				}
+				failed += n
+			}
+		}
+
+		store.Close()
+
+		if *every <= 0 {
+			if errored > 0 {
+				return fmt.Errorf("failed to apply %d policies, %d cards not updated", errored, failed)
+			}
+
+			if failed > 0 {
+				return fmt.Errorf("failed to update %d cards", failed)
+			}
+
+			return nil
+		}
+
+		log.Info("Waiting for next cleanup", "at", time.Now().Add(*every).Format(time.DateTime))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(*every):
+		}
+
+		// The session may have expired while waiting, Login reuses it if not
+		for {
+			err := client.Login(ctx)
+			if err == nil {
+				break
+			}
+
+			log.Error("Failed to log in, retrying", "at", time.Now().Add(*every).Format(time.DateTime), "error", err)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(*every):
+			}
+		}
+	}
+}
+
+// applyPolicy runs policy against the tokens of card and returns how many
+// updates failed.
+func applyPolicy(ctx context.Context, profile *Profile, capWeb *web.Web, store *inventory.Store, card extension.PaymentCard, policy CleanupPolicy, dryRun bool, yes bool, opts executeOptions) (int, error) {
+	query, err := policy.query(time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	tokens, err := fetchTokens(ctx, capWeb, card, query)
+	if err != nil {
+		return 0, err
+	}
+
+	log.Info("Evaluated policy", "policy", policy.Name, "action", policy.Action, "card", card.CardNumber, "matches", len(tokens))
+	if len(tokens) == 0 {
+		return 0, nil
+	}
+
+	newEntry := func(token web.ListedToken) AuditEntry {
+		return AuditEntry{
+			Time:             time.Now(),
+			Policy:           policy.Name,
+			Action:           policy.Action,
+			CardReferenceID:  card.CardReferenceID,
+			TokenReferenceID: token.TokenReferenceID,
+			TokenName:        token.TokenName,
+			TokenLastFour:    token.TokenLastFour,
+			DryRun:           dryRun,
+		}
+	}
+
+	if dryRun {
+		entries := []AuditEntry{}
+		for _, token := range tokens {
+			fmt.Printf("- would %s %s (%s)\n", policy.Action, token.TokenName, token.TokenLastFour)
+			entries = append(entries, newEntry(token))
+		}
+
+		return 0, appendAudit(profile, entries...)
+	}
+
+	if !yes {
+		for i, token := range tokens {
+			fmt.Printf("%d. %s (%s)\n", i+1, token.TokenName, token.TokenLastFour)
+		}
+
+		if ask(fmt.Sprintf("Policy %q will %s these cards. Continue? (y/n)", policy.Name, policy.Action)) != "y" {
+			return 0, nil
+		}
+	}
+
+	if policy.Action == CleanupActionPause {
+		path, err := saveSnapshot(profile, "cleanup", card, tokens)
+		if err != nil {
+			return 0, fmt.Errorf("save snapshot: %w", err)
+		}
+		log.Info("Saved snapshot", "path", path)
+	}
+
+	failed := 0
+	var auditErr error
+	execute(ctx, tokens, opts, func(ctx context.Context, token web.ListedToken) error {
+		if policy.Action == CleanupActionDelete {
+			return capWeb.DeleteToken(ctx, card, token)
+		}
+
+		return capWeb.SetTokenAuthorizations(ctx, card, token, false)
+	}, func(finished int, result executeResult[web.ListedToken]) {
+		token := result.Item
+		progress := fmt.Sprintf("(%d/%d)", finished, len(tokens))
+
+		entry := newEntry(token)
+		if result.Err != nil {
+			failed++
+			entry.Error = result.Err.Error()
+			log.Error(progress+" Failed to apply policy", "policy", policy.Name, "card", token.TokenName, "error", result.Err)
+		} else {
+			log.Info(progress+" Applied policy", "policy", policy.Name, "action", policy.Action, "card", token.TokenName)
+
+			if policy.Action == CleanupActionDelete {
+				if err := store.MarkDeleted(token.TokenReferenceID); err != nil {
+					log.Error("Failed to update inventory", "error", err)
+				}
+			}
+		}
+
+		if err := appendAudit(profile, entry); err != nil {
+			auditErr = err
+		}
+	})
+
+	if auditErr != nil {
+		return failed, fmt.Errorf("write audit log: %w", auditErr)
+	}
+
+	return failed, nil
+}
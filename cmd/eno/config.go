@@ -0,0 +1,287 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/saucesteals/eno/api"
+)
+
+// Settings are the knobs that can be set globally or per profile. Later
+// sources win: defaults, the config file, the profile's section of the config
+// file, environment variables and finally flags.
+type Settings struct {
+	BrowserBinary string        `toml:"browser_binary"`
+	CreateDelay   time.Duration `toml:"create_delay"`
+	Retries       int           `toml:"retries"`
+	PageSize      int           `toml:"page_size"`
+	LogLevel      string        `toml:"log_level"`
+	DefaultCard   string        `toml:"default_card"`
+	DefaultMode   string        `toml:"default_mode"`
+	OutputFormat  string        `toml:"output_format"`
+	ExportDir     string        `toml:"export_dir"`
+}
+
+// config holds the settings of the profile in use.
+var config = defaultSettings()
+
+func defaultSettings() Settings {
+	browserBin := "/Applications/Google Chrome.app/Contents/MacOS/Google Chrome"
+	if runtime.GOOS == "windows" {
+		browserBin = "C:\\Program Files\\Google\\Chrome\\Application\\chrome.exe"
+	}
+
+	return Settings{
+		BrowserBinary: browserBin,
+		CreateDelay:   time.Second * 5,
+		Retries:       3,
+		PageSize:      50,
+		LogLevel:      "info",
+		OutputFormat:  "table",
+	}
+}
+
+// knob is a setting that can be overridden by an environment variable and a
+// flag of the same name.
+type knob struct {
+	flag  string
+	env   string
+	usage string
+	set   func(s *Settings, value string) error
+}
+
+var knobs = []knob{
+	{"browser-binary", "ENO_BROWSER_BINARY", "browser `path` used to log in", func(s *Settings, value string) error {
+		s.BrowserBinary = value
+		return nil
+	}},
+	{"create-delay", "ENO_CREATE_DELAY", "`duration` to wait between created cards", func(s *Settings, value string) (err error) {
+		s.CreateDelay, err = time.ParseDuration(value)
+		return err
+	}},
+	{"retries", "ENO_RETRIES", "`attempts` to create each card", func(s *Settings, value string) (err error) {
+		s.Retries, err = strconv.Atoi(value)
+		return err
+	}},
+	{"page-size", "ENO_PAGE_SIZE", "`count` of cards requested per page", func(s *Settings, value string) (err error) {
+		s.PageSize, err = strconv.Atoi(value)
+		return err
+	}},
+	{"log-level", "ENO_LOG_LEVEL", "`level` to log at (debug, info, warn or error)", func(s *Settings, value string) error {
+		s.LogLevel = value
+		return nil
+	}},
+	{"card", "ENO_CARD", "select the card whose number ends with or description contains `card`", func(s *Settings, value string) error {
+		s.DefaultCard = value
+		return nil
+	}},
+	{"mode", "ENO_MODE", "default create `mode` (web, extension or auto)", func(s *Settings, value string) error {
+		s.DefaultMode = value
+		return nil
+	}},
+	{"output-format", "ENO_OUTPUT_FORMAT", "default report `format` (table, csv or json)", func(s *Settings, value string) error {
+		s.OutputFormat = value
+		return nil
+	}},
+	{"export-dir", "ENO_EXPORT_DIR", "`directory` card exports are written to", func(s *Settings, value string) error {
+		s.ExportDir = value
+		return nil
+	}},
+}
+
+// Config is the config file along with the environment and flags given to
+// eno.
+type Config struct {
+	// Home is the program directory holding the profiles
+	Home string
+	// Profile is the username to use without asking for it
+	Profile string
+
+	global   Settings
+	profiles map[string]toml.Primitive
+	meta     toml.MetaData
+	flags    map[string]string
+}
+
+type configFile struct {
+	Home string `toml:"home"`
+	Settings
+	Profiles map[string]toml.Primitive `toml:"profiles"`
+}
+
+// configPath returns where the config file is looked for: in ENO_HOME if set,
+// in the user's config directory ($XDG_CONFIG_HOME/eno) otherwise.
+func configPath() (string, error) {
+	if home := os.Getenv("ENO_HOME"); home != "" {
+		return filepath.Join(home, "config.toml"), nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "eno", "config.toml"), nil
+}
+
+// loadConfig parses the global flags in args and reads the config file. It
+// returns the remaining arguments, the command to run.
+func loadConfig(args []string) (*Config, []string, error) {
+	fs := flag.NewFlagSet("eno", flag.ContinueOnError)
+	path := fs.String("config", "", "read the config from `path`")
+	home := fs.String("home", "", "program `directory` holding the profiles")
+	profileName := fs.String("profile", os.Getenv("ENO_PROFILE"), "`username` of the profile to use")
+
+	flags := map[string]string{}
+	for _, k := range knobs {
+		fs.Func(k.flag, k.usage, func(value string) error {
+			flags[k.flag] = value
+			return nil
+		})
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	file := configFile{Settings: defaultSettings()}
+
+	explicit := *path != ""
+	if !explicit {
+		var err error
+		*path, err = configPath()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	meta, err := toml.DecodeFile(*path, &file)
+	if err != nil && (explicit || !errors.Is(err, os.ErrNotExist)) {
+		return nil, nil, fmt.Errorf("config: %w", err)
+	}
+
+	c := &Config{
+		Home:     cmp.Or(*home, os.Getenv("ENO_HOME"), file.Home),
+		Profile:  *profileName,
+		global:   file.Settings,
+		profiles: file.Profiles,
+		meta:     meta,
+		flags:    flags,
+	}
+
+	// Every section is decoded once so mistakes are reported upfront
+	for name := range c.profiles {
+		if _, err := c.For(name); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+		return nil, nil, fmt.Errorf("config %s: unknown key %s", *path, undecoded[0])
+	}
+
+	if c.Home != "" {
+		c.Home = expandHome(c.Home)
+	}
+
+	return c, fs.Args(), nil
+}
+
+// For returns the settings of the profile username, or the global settings if
+// username is empty.
+func (c *Config) For(username string) (Settings, error) {
+	s := c.global
+	if section, ok := c.profiles[username]; ok {
+		if err := c.meta.PrimitiveDecode(section, &s); err != nil {
+			return s, fmt.Errorf("config profiles.%s: %w", username, err)
+		}
+	}
+
+	for _, k := range knobs {
+		if value := os.Getenv(k.env); value != "" {
+			if err := k.set(&s, value); err != nil {
+				return s, fmt.Errorf("%s: %w", k.env, err)
+			}
+		}
+	}
+
+	for _, k := range knobs {
+		if value, ok := c.flags[k.flag]; ok {
+			if err := k.set(&s, value); err != nil {
+				return s, fmt.Errorf("--%s: %w", k.flag, err)
+			}
+		}
+	}
+
+	if s.ExportDir != "" {
+		s.ExportDir = expandHome(s.ExportDir)
+	}
+
+	return s, s.validate()
+}
+
+func (s Settings) validate() error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s.LogLevel)); err != nil {
+		return fmt.Errorf("log level: %w", err)
+	}
+
+	if s.Retries < 1 {
+		return fmt.Errorf("retries must be at least 1, got %d", s.Retries)
+	}
+
+	if s.PageSize < 1 {
+		return fmt.Errorf("page size must be at least 1, got %d", s.PageSize)
+	}
+
+	if s.CreateDelay < 0 {
+		return fmt.Errorf("create delay must not be negative, got %s", s.CreateDelay)
+	}
+
+	switch api.TokenMode(s.DefaultMode) {
+	case "", api.TokenModeWeb, api.TokenModeExtension, api.TokenModeAuto:
+	default:
+		return fmt.Errorf("default mode must be web, extension or auto, got %q", s.DefaultMode)
+	}
+
+	switch s.OutputFormat {
+	case "table", "csv", "json":
+	default:
+		return fmt.Errorf("output format must be table, csv or json, got %q", s.OutputFormat)
+	}
+
+	return nil
+}
+
+// apply makes s the settings in use.
+func (s Settings) apply() {
+	var level slog.Level
+	level.UnmarshalText([]byte(s.LogLevel))
+	logLevel.Set(level)
+
+	config = s
+}
+
+func expandHome(path string) string {
+	rest, ok := strings.CutPrefix(path, "~")
+	if !ok || (rest != "" && rest[0] != '/' && rest[0] != filepath.Separator) {
+		return path
+	}
+
+	userHome, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+
+	return filepath.Join(userHome, rest)
+}
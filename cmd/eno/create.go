@@ -1,28 +1,34 @@
 package main
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/saucesteals/eno"
 	"github.com/saucesteals/eno/api"
 	"github.com/saucesteals/eno/extension"
+	"github.com/saucesteals/eno/inventory"
 	"github.com/saucesteals/eno/web"
 )
 
-type CreateMode string
+func create(ctx context.Context, profile *Profile, client *eno.Client, store *inventory.Store, card extension.PaymentCard) error {
+	capExt := client.Extension
 
-var (
-	CreateModeWeb       CreateMode = "web"
-	CreateModeExtension CreateMode = "extension"
-)
+	prompt := fmt.Sprintf("Enter mode (%s/%s/%s)", api.TokenModeWeb, api.TokenModeExtension, api.TokenModeAuto)
+	if config.DefaultMode != "" {
+		prompt += fmt.Sprintf(" [%s]", config.DefaultMode)
+	}
 
-func create(ctx context.Context, profile *Profile, capWeb *web.Web, capExt *extension.Extension, card extension.PaymentCard) error {
-	mode := CreateMode(ask(fmt.Sprintf("Enter mode (%s/%s)", CreateModeWeb, CreateModeExtension)))
-	if mode != CreateModeWeb && mode != CreateModeExtension {
-		return fmt.Errorf("invalid mode: %s", mode)
+	mode := api.TokenMode(cmp.Or(ask(prompt), config.DefaultMode))
+	provider, err := client.Provider(mode)
+	if err != nil {
+		return err
 	}
 
 	count, err := strconv.Atoi(ask("Enter number of cards to create"))
@@ -30,88 +36,57 @@ func create(ctx context.Context, profile *Profile, capWeb *web.Web, capExt *exte
 		return fmt.Errorf("invalid number of cards: %w", err)
 	}
 
-	var merchant *extension.DataSource
+	req := api.TokenRequest{CardReferenceID: card.CardReferenceID}
 	var cardPrefix string
-	if mode == CreateModeWeb {
+	if mode == api.TokenModeWeb || mode == api.TokenModeAuto {
 		cardPrefix = "Web"
 
-		assessment, err := capWeb.ChallengeAssessment(ctx, card)
+		req, err = askWebOptions(ctx, capExt, req)
 		if err != nil {
-			return fmt.Errorf("challenge assessment: %w", err)
+			return err
 		}
 
-		if assessment.RedirectURL == "" {
-			if len(assessment.AvailableMethods) == 0 {
-				return fmt.Errorf("no available methods found")
-			}
-
-			smsContactPoints := []web.ChallengeContactPoint{}
-			for _, contactPoint := range assessment.AvailableMethods[0].AvailableMethodsPayload.ContactPoints {
-				if contactPoint.ContactPointDeliveryMediums.IsSms {
-					smsContactPoints = append(smsContactPoints, contactPoint)
-				}
-			}
-
-			if len(smsContactPoints) == 0 {
-				return fmt.Errorf("no sms contact points found")
-			}
-
-			fmt.Printf("Contact Points:\n")
-			for i, contactPoint := range smsContactPoints {
-				fmt.Printf("%d. %s\n", i+1, contactPoint.ContactPointMasked)
-			}
-
-			contactPointIndex, err := strconv.Atoi(ask("Select a contact point"))
-			if err != nil {
-				return fmt.Errorf("invalid contact point")
-			}
-
-			contactPointIndex--
-			if contactPointIndex < 0 || contactPointIndex > len(smsContactPoints) {
-				return fmt.Errorf("unknown contact point")
-			}
-
-			smsContactPoint := smsContactPoints[contactPointIndex]
-
-			otp, err := capWeb.ChallengeVerification(ctx, assessment.PolicyProcessID, smsContactPoint)
-			if err != nil {
-				return fmt.Errorf("challenge verification: %w", err)
-			}
-
-			otpValue := ask("Enter OTP value sent to " + smsContactPoint.ContactPointMasked)
-			if err := capWeb.ChallengeValidation(ctx, assessment.PolicyProcessID, otp.Otp, otpValue); err != nil {
-				return fmt.Errorf("challenge validation: %w", err)
-			}
+		if req.Merchant != nil {
+			cardPrefix = req.Merchant.Name
 		}
 	} else {
-		merchantUrl := ask("Enter merchant URL (e.g. www.google.com)")
-
-		m, err := capExt.DataSourceSearch(ctx, merchantUrl)
+		merchant, err := askMerchant(ctx, capExt, "Enter merchant URL (e.g. www.google.com)", false)
 		if err != nil {
-			return fmt.Errorf("failed to search for merchant: %w", err)
+			return err
 		}
 
-		merchant = &m
+		req.Merchant = merchant
 		cardPrefix = merchant.Name
 	}
 
-	w, err := NewCardWriter(profile, card, cardPrefix)
+	if err := provider.Capabilities().Check(req); err != nil {
+		return err
+	}
+
+	// The file is named after the mode the cards are created with, which auto
+	// mode only knows up front when the options leave a single provider
+	fileMode := mode
+	if strategy, ok := provider.(*api.Strategy); ok {
+		if modes := strategy.Modes(req); len(modes) == 1 {
+			fileMode = modes[0]
+			log.Warn("Only one mode supports these options, auto mode will not fail over", "mode", fileMode)
+		}
+	}
+
+	w, err := NewCardWriter(profile, card, cardPrefix+" "+string(fileMode))
 	if err != nil {
 		return fmt.Errorf("new card writer: %w", err)
 	}
 	defer w.Close()
 
-	delay := time.Second * 5
-	maxTries := 3
+	delay := config.CreateDelay
+	maxTries := config.Retries
+	modes := map[api.TokenMode]int{}
 	for i := range count {
-		name := fmt.Sprintf("%s Card %d", cardPrefix, i+1)
-		var token api.Token
+		req.Name = fmt.Sprintf("%s Card %d", cardPrefix, i+1)
+		var result api.TokenResult
 		for j := range maxTries {
-			if mode == CreateModeWeb {
-				token, err = capWeb.CreateToken(ctx, name, card)
-			} else {
-				token, err = capExt.CreateToken(ctx, name, card, *merchant)
-			}
+			result, err = provider.Create(ctx, req)
 			if err != nil {
 				if j == maxTries-1 {
 					return fmt.Errorf("create token: %w", err)
@@ -130,18 +105,87 @@ func create(ctx context.Context, profile *Profile, capWeb *web.Web, capExt *exte
 			break
 		}
 
-		log.Info(fmt.Sprintf("(%d/%d) Created token", i+1, count), "token", token.Token)
+		// The web provider steps up before its first token, keep the
+		// authorization for later commands
+		if result.Mode == api.TokenModeWeb {
+			if err := client.Save(); err != nil {
+				log.Error("Failed to save session", "error", err)
+			}
+		}
+
+		token := result.Token
+		modes[result.Mode]++
+		log.Info(fmt.Sprintf("(%d/%d) Created token", i+1, count), "token", token.Token, "mode", result.Mode)
 
 		err = w.Write(token)
 		if err != nil {
 			return fmt.Errorf("write token: %w", err)
 		}
 
+		if token.TokenReferenceID != "" {
+			err = store.Put(inventory.NewCreatedRecord(card, token, string(result.Mode), w.GetBatch(), w.GetPath()))
+			if err != nil {
+				log.Error("Failed to record token in inventory", "error", err)
+			}
+		}
+
 		if i < count-1 {
 			time.Sleep(delay)
 		}
 	}
 
-	log.Info("Created cards", "count", count, "path", w.GetPath())
+	log.Info("Created cards", "count", count, "web", modes[api.TokenModeWeb], "extension", modes[api.TokenModeExtension], "path", w.GetPath())
 	return nil
 }
+
+// askMerchant looks up the merchant at a URL entered by the user. It returns
+// nil if the merchant is optional and none was entered.
+func askMerchant(ctx context.Context, capExt *extension.Extension, prompt string, optional bool) (*api.Merchant, error) {
+	merchantUrl := ask(prompt)
+	if merchantUrl == "" && optional {
+		return nil, nil
+	}
+
+	return findMerchant(ctx, capExt, merchantUrl)
+}
+
+// findMerchant looks up the merchant at merchantUrl.
+func findMerchant(ctx context.Context, capExt *extension.Extension, merchantUrl string) (*api.Merchant, error) {
+	m, err := capExt.DataSourceSearch(ctx, merchantUrl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search for merchant: %w", err)
+	}
+
+	return &api.Merchant{
+		MdxID:    m.MDXId,
+		MdxURLID: m.MDXUrlId,
+		Name:     m.Name,
+		URL:      m.MerchantUrl,
+	}, nil
+}
+
+func askWebOptions(ctx context.Context, capExt *extension.Extension, req api.TokenRequest) (api.TokenRequest, error) {
+	merchant, err := askMerchant(ctx, capExt, "Enter merchant URL to bind to (optional)", true)
+	if err != nil {
+		return req, err
+	}
+	req.Merchant = merchant
+
+	req.OneTimeUse = ask("One-time use? (y/n)") == "y"
+
+	durations := []string{}
+	for _, duration := range web.TokenDurations {
+		if duration == web.TokenDurationUnlimited {
+			continue
+		}
+		durations = append(durations, string(duration))
+	}
+
+	duration := web.TokenDuration(strings.ToUpper(ask(fmt.Sprintf("Enter duration (%s, optional)", strings.Join(durations, "/")))))
+	if !slices.Contains(web.TokenDurations, duration) {
+		return req, fmt.Errorf("invalid duration: %s", duration)
+	}
+	req.Duration = string(duration)
+
+	return req, nil
+}
@@ -2,60 +2,222 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/saucesteals/eno/extension"
+	"github.com/saucesteals/eno/inventory"
 	"github.com/saucesteals/eno/web"
 )
 
-func delete(ctx context.Context, capWeb *web.Web, card extension.PaymentCard) error {
-	nameFilter := ask("Enter name filter (optional)")
+type DeleteResult string
 
-	minDayAgeString := ask("Enter minimum age in days (optional)")
-	if minDayAgeString == "" {
-		minDayAgeString = "0"
-	}
+var (
+	DeleteResultDeleted DeleteResult = "deleted"
+	DeleteResultFailed  DeleteResult = "failed"
+	DeleteResultSkipped DeleteResult = "skipped"
+	DeleteResultDryRun  DeleteResult = "dry-run"
+)
+
+type DeleteReportEntry struct {
+	TokenReferenceID string       `json:"tokenReferenceId"`
+	TokenName        string       `json:"tokenName"`
+	TokenLastFour    string       `json:"tokenLastFour"`
+	MerchantName     string       `json:"merchantName,omitempty"`
+	Result           DeleteResult `json:"result"`
+	Attempts         int          `json:"attempts,omitempty"`
+	Error            string       `json:"error,omitempty"`
+}
+
+// DeleteReport is written after every delete so the outcome of each card can
+// be audited or processed by other tools.
+type DeleteReport struct {
+	StartedAt       time.Time           `json:"startedAt"`
+	FinishedAt      time.Time           `json:"finishedAt"`
+	CardReferenceID string              `json:"cardReferenceId"`
+	Args            []string            `json:"args"`
+	DryRun          bool                `json:"dryRun"`
+	Deleted         int                 `json:"deleted"`
+	Failed          int                 `json:"failed"`
+	Skipped         int                 `json:"skipped"`
+	Tokens          []DeleteReportEntry `json:"tokens"`
+}
 
-	minDayAge, err := strconv.Atoi(minDayAgeString)
+func (r *DeleteReport) add(token web.ListedToken, result DeleteResult, attempts int, err error) {
+	entry := DeleteReportEntry{
+		TokenReferenceID: token.TokenReferenceID,
+		TokenName:        token.TokenName,
+		TokenLastFour:    token.TokenLastFour,
+		MerchantName:     token.MdxInfo.Name,
+		Result:           result,
+		Attempts:         attempts,
+	}
 	if err != nil {
-		return fmt.Errorf("invalid minimum day age: %w", err)
+		entry.Error = err.Error()
 	}
 
-	cards := []web.ListedToken{}
-	total := 0
-	for offset := 0; ; offset += 1 {
-		page, err := capWeb.ListTokens(ctx, card, nameFilter, offset, 50)
+	switch result {
+	case DeleteResultDeleted:
+		r.Deleted++
+	case DeleteResultFailed:
+		r.Failed++
+	case DeleteResultSkipped:
+		r.Skipped++
+	}
+
+	r.Tokens = append(r.Tokens, entry)
+}
+
+// saveReport writes v as JSON to path, or to the profile's reports directory
+// when path is empty.
+func saveReport(profile *Profile, path string, action string, v any) (string, error) {
+	if path == "" {
+		dir, err := profile.GetDirectory("reports")
 		if err != nil {
-			return fmt.Errorf("list tokens: %w", err)
+			return "", err
 		}
 
-		for _, token := range page.Entries {
-			if minDayAge > 0 {
-				tokenCreatedAt, err := time.ParseInLocation("2006-01-02T15:04:05", token.TokenCreatedTimestamp, time.UTC)
-				if err != nil {
-					return fmt.Errorf("parse token created at: %w", err)
-				}
+		path = filepath.Join(dir, fmt.Sprintf("%s_%s.json", time.Now().Format("2006_01_02_15_04_05"), action))
+	}
 
-				dayAge := int(time.Since(tokenCreatedAt).Hours() / 24)
-				if dayAge < minDayAge {
-					log.Info("Skipping card", "card", token.TokenName, "age", dayAge)
-					continue
-				}
+	contents, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.WriteFile(path, contents, 0600); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// criteriaFlags are the delete flags that narrow down which cards match.
+var criteriaFlags = []string{
+	"name", "regex", "merchant", "status", "derived-status", "type", "last-four",
+	"expired", "not-updated-days", "min-age", "created-before", "created-after",
+}
+
+func delete(ctx context.Context, profile *Profile, capWeb *web.Web, store *inventory.Store, card extension.PaymentCard, args []string) error {
+	fs := flag.NewFlagSet("delete", flag.ContinueOnError)
+	name := fs.String("name", "", "match names containing `text`")
+	pattern := fs.String("regex", "", "match names against a regular `expression`")
+	merchant := fs.String("merchant", "", "match cards bound to a merchant whose name or URL contains `text`")
+	statuses := fs.String("status", "", "comma separated token `statuses` (e.g. ACTIVE,SUSPENDED)")
+	derivedStatuses := fs.String("derived-status", "", "comma separated derived `statuses`")
+	tokenTypes := fs.String("type", "", "comma separated token `types`")
+	lastFour := fs.String("last-four", "", "comma separated `digits` cards end in")
+	expired := fs.Bool("expired", false, "match expired cards only")
+	notUpdatedDays := fs.Int("not-updated-days", 0, "match cards not updated in the last `days` (updates, not transactions)")
+	minAge := fs.Int("min-age", 0, "match cards at least `days` old")
+	createdBefore := fs.String("created-before", "", "match cards created before `date` (YYYY-MM-DD)")
+	createdAfter := fs.String("created-after", "", "match cards created on or after `date` (YYYY-MM-DD)")
+	dryRun := fs.Bool("dry-run", false, "report matching cards without deleting them")
+	confirmEach := fs.Bool("confirm-each", false, "confirm every card individually")
+	yes := fs.Bool("yes", false, "delete without asking for confirmation")
+	concurrency := fs.Int("concurrency", 4, "number of cards deleted at once")
+	retries := fs.Int("retries", 2, "number of times a failed deletion is retried")
+	reportPath := fs.String("report", "", "write the JSON report to `path` instead of the profile's reports directory")
+
+	if ok, err := parseFlags(fs, args); !ok {
+		return err
+	}
+
+	hasCriteria := false
+	fs.Visit(func(f *flag.Flag) {
+		for _, name := range criteriaFlags {
+			hasCriteria = hasCriteria || f.Name == name
+		}
+	})
+
+	if !hasCriteria {
+		*name = ask("Enter name filter (optional)")
+
+		minDayAgeString := ask("Enter minimum age in days (optional)")
+		if minDayAgeString != "" {
+			minDayAge, err := strconv.Atoi(minDayAgeString)
+			if err != nil {
+				return fmt.Errorf("invalid minimum day age: %w", err)
 			}
+			*minAge = minDayAge
+		}
+	}
+
+	query := web.NewTokenQuery().Name(*name).Merchant(*merchant)
 
-			cards = append(cards, token)
+	if *pattern != "" {
+		re, err := regexp.Compile(*pattern)
+		if err != nil {
+			return fmt.Errorf("invalid regex: %w", err)
 		}
+		query = query.NamePattern(re)
+	}
+
+	for _, status := range splitList(*statuses) {
+		query = query.Status(web.TokenStatus(strings.ToUpper(status)))
+	}
+
+	if list := splitList(*derivedStatuses); len(list) > 0 {
+		query = query.DerivedStatus(list...)
+	}
+
+	if list := splitList(*tokenTypes); len(list) > 0 {
+		query = query.Type(list...)
+	}
 
-		total += len(page.Entries)
-		if page.Count <= total {
-			break
+	if list := splitList(*lastFour); len(list) > 0 {
+		query = query.LastFour(list...)
+	}
+
+	if *expired {
+		query = query.Expired(true)
+	}
+
+	if *notUpdatedDays > 0 {
+		query = query.UpdatedBetween(time.Time{}, time.Now().AddDate(0, 0, -*notUpdatedDays))
+	}
+
+	var from, to time.Time
+	if *minAge > 0 {
+		to = time.Now().AddDate(0, 0, -*minAge)
+	}
+
+	if *createdBefore != "" {
+		before, err := parseDate(*createdBefore)
+		if err != nil {
+			return fmt.Errorf("invalid created-before: %w", err)
+		}
+		if to.IsZero() || before.Before(to) {
+			to = before
 		}
 	}
 
+	if *createdAfter != "" {
+		after, err := parseDate(*createdAfter)
+		if err != nil {
+			return fmt.Errorf("invalid created-after: %w", err)
+		}
+		from = after
+	}
+
+	if !from.IsZero() || !to.IsZero() {
+		query = query.CreatedBetween(from, to)
+	}
+
+	cards, err := fetchTokens(ctx, capWeb, card, query)
+	if err != nil {
+		return err
+	}
+
 	for i, card := range cards {
-		fmt.Printf("%d. %s (%s)\n", i+1, card.TokenName, card.TokenLastFour)
+		fmt.Printf("%d. %s (%s) %s\n", i+1, card.TokenName, card.TokenLastFour, card.DerivedStatus)
 	}
 
 	log.Info("Found cards", "count", len(cards))
@@ -63,35 +225,83 @@ func delete(ctx context.Context, capWeb *web.Web, card extension.PaymentCard) er
 		return nil
 	}
 
-	confirm := ask("Are you sure you want to delete these cards? (y/n)")
-	if confirm != "y" {
-		return nil
+	report := DeleteReport{
+		StartedAt:       time.Now(),
+		CardReferenceID: card.CardReferenceID,
+		Args:            args,
+		DryRun:          *dryRun,
+		Tokens:          []DeleteReportEntry{},
 	}
 
-	cardLastFour := card.CardNumber[len(card.CardNumber)-4:]
-	for i, token := range cards {
-		fmt.Printf("(%d/%d) Deleting %s... ", i+1, len(cards), token.TokenName)
-
-		err = capWeb.UpdateToken(ctx, web.UpdateTokenPayload{
-			AllowAuthorizations: true,
-			CardLastFour:        cardLastFour,
-			CardName:            card.ProductDescription,
-			CardReferenceID:     card.CardReferenceID,
-			IsDeleted:           true,
-			MdxID:               token.MdxInfo.MdxID,
-			MdxURLID:            token.MdxInfo.MdxURLID,
-			TokenDuration:       nil,
-			TokenLastFour:       token.TokenLastFour,
-			TokenName:           token.TokenName,
-			TokenReferenceID:    token.TokenReferenceID,
-		})
-		if err != nil {
-			fmt.Println("Failed")
-			log.Error("Failed to delete token", "error", err)
-			continue
+	selected := cards
+	switch {
+	case *dryRun:
+		for _, token := range cards {
+			report.add(token, DeleteResultDryRun, 0, nil)
+		}
+		selected = nil
+	case *confirmEach:
+		selected = []web.ListedToken{}
+		all := false
+	confirm:
+		for i, token := range cards {
+			answer := "y"
+			if !all {
+				answer = ask(fmt.Sprintf("Delete %s (%s)? (y/n/a/q)", token.TokenName, token.TokenLastFour))
+			}
+
+			switch answer {
+			case "a":
+				all = true
+				selected = append(selected, token)
+			case "y":
+				selected = append(selected, token)
+			case "q":
+				for _, token := range cards[i:] {
+					report.add(token, DeleteResultSkipped, 0, nil)
+				}
+				break confirm
+			default:
+				report.add(token, DeleteResultSkipped, 0, nil)
+			}
+		}
+	case !*yes:
+		if ask("Are you sure you want to delete these cards? (y/n)") != "y" {
+			return nil
 		}
+	}
+
+	execute(ctx, selected, executeOptions{Concurrency: *concurrency, Retries: *retries}, func(ctx context.Context, token web.ListedToken) error {
+		return capWeb.DeleteToken(ctx, card, token)
+	}, func(finished int, result executeResult[web.ListedToken]) {
+		token := result.Item
+		progress := fmt.Sprintf("(%d/%d)", finished, len(selected))
+
+		if result.Err != nil {
+			report.add(token, DeleteResultFailed, result.Attempts, result.Err)
+			log.Error(progress+" Failed to delete card", "card", token.TokenName, "attempts", result.Attempts, "error", result.Err)
+			return
+		}
+
+		report.add(token, DeleteResultDeleted, result.Attempts, nil)
+		log.Info(progress+" Deleted card", "card", token.TokenName)
+
+		if err := store.MarkDeleted(token.TokenReferenceID); err != nil {
+			log.Error("Failed to update inventory", "error", err)
+		}
+	})
+
+	report.FinishedAt = time.Now()
+
+	path, err := saveReport(profile, *reportPath, "delete", report)
+	if err != nil {
+		return fmt.Errorf("save report: %w", err)
+	}
+
+	log.Info("Saved report", "path", path, "deleted", report.Deleted, "failed", report.Failed, "skipped", report.Skipped)
 
-		fmt.Println("Done")
+	if report.Failed > 0 {
+		return fmt.Errorf("failed to delete %d cards", report.Failed)
 	}
 
 	return nil
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/saucesteals/eno/api"
+)
+
+type executeOptions struct {
+	// Concurrency is how many items are processed at once, at least 1
+	Concurrency int
+	// Retries is how many times a failed item is retried
+	Retries int
+}
+
+type executeResult[T any] struct {
+	Item     T
+	Attempts int
+	Err      error
+}
+
+// execute runs fn for every item with at most opts.Concurrency calls in flight,
+// retrying failures with a backoff. done is called as each item finishes and
+// results are returned in the order of items.
+func execute[T any](ctx context.Context, items []T, opts executeOptions, fn func(ctx context.Context, item T) error, done func(finished int, result executeResult[T])) []executeResult[T] {
+	concurrency := max(opts.Concurrency, 1)
+
+	results := make([]executeResult[T], len(items))
+	slots := make(chan struct{}, concurrency)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	finished := 0
+
+	for i, item := range items {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			for j := i; j < len(items); j++ {
+				results[j] = executeResult[T]{Item: items[j], Err: ctx.Err()}
+			}
+			wg.Wait()
+			return results
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			result := executeResult[T]{Item: item}
+			for attempt := range opts.Retries + 1 {
+				result.Attempts = attempt + 1
+				result.Err = fn(ctx, item)
+				if result.Err == nil || attempt == opts.Retries || !sleepBeforeRetry(ctx, result.Err, attempt) {
+					break
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			results[i] = result
+			finished++
+			if done != nil {
+				done(finished, result)
+			}
+		}()
+	}
+
+	wg.Wait()
+	return results
+}
+
+// sleepBeforeRetry waits longer after each attempt, and two minutes when rate
+// limited. It reports false if ctx ended first.
+func sleepBeforeRetry(ctx context.Context, err error, attempt int) bool {
+	delay := time.Second * 2 * time.Duration(attempt+1)
+	if errors.Is(err, api.ErrRateLimited) {
+		delay = time.Minute * 2
+	}
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(delay):
+		return true
+	}
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/saucesteals/eno"
+	"github.com/saucesteals/eno/api"
+	"github.com/saucesteals/eno/extension"
+	"github.com/saucesteals/eno/inventory"
+	"github.com/saucesteals/eno/web"
+)
+
+// Renewal maps an expiring card to its replacement so merchants can be
+// updated.
+type Renewal struct {
+	OldTokenReferenceID string     `json:"oldTokenReferenceId"`
+	OldTokenName        string     `json:"oldTokenName"`
+	OldTokenLastFour    string     `json:"oldTokenLastFour"`
+	OldExpirationDate   string     `json:"oldExpirationDate"`
+	NewTokenReferenceID string     `json:"newTokenReferenceId"`
+	NewTokenLastFour    string     `json:"newTokenLastFour"`
+	NewExpirationDate   string     `json:"newExpirationDate"`
+	MerchantName        string     `json:"merchantName,omitempty"`
+	MerchantURL         string     `json:"merchantUrl,omitempty"`
+	DeleteAfter         *time.Time `json:"deleteAfter,omitempty"`
+	OldDeleted          bool       `json:"oldDeleted"`
+}
+
+type RenewalMapping struct {
+	CreatedAt       time.Time `json:"createdAt"`
+	CardReferenceID string    `json:"cardReferenceId"`
+	ExportPath      string    `json:"exportPath"`
+	Renewals        []Renewal `json:"renewals"`
+}
+
+func saveRenewalMapping(path string, mapping RenewalMapping) error {
+	contents, err := json.MarshalIndent(mapping, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, contents, 0600)
+}
+
+func expiry(ctx context.Context, profile *Profile, client *eno.Client, store *inventory.Store, card extension.PaymentCard, args []string) error {
+	capWeb := client.Web
+
+	fs := flag.NewFlagSet("expiry", flag.ContinueOnError)
+	days := fs.Int("days", 30, "report cards expiring within `days`")
+	renew := fs.Bool("renew", false, "create a replacement for every reported card")
+	deleteOld := fs.Bool("delete-old", false, "delete renewed cards once the grace period has passed")
+	grace := fs.Duration("grace", 0, "how long renewed cards are kept before they are deleted")
+	yes := fs.Bool("yes", false, "renew without asking for confirmation")
+
+	if ok, err := parseFlags(fs, args); !ok {
+		return err
+	}
+
+	dir, err := profile.GetDirectory("renewals")
+	if err != nil {
+		return err
+	}
+
+	if err := deleteRenewed(ctx, capWeb, store, card, dir); err != nil {
+		return err
+	}
+
+	tokens, err := fetchTokens(ctx, capWeb, card, web.NewTokenQuery())
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	deadline := now.AddDate(0, 0, *days)
+
+	expiring := []web.ListedToken{}
+	for _, token := range tokens {
+		expiresAt, err := token.ExpiresAt()
+		if err != nil {
+			log.Error("Failed to parse expiration date", "card", token.TokenName, "error", err)
+			continue
+		}
+
+		state := ""
+		switch {
+		case token.Expired():
+			state = "expired"
+		case expiresAt.Before(deadline):
+			state = fmt.Sprintf("expires in %d days", int(expiresAt.Sub(now).Hours()/24))
+		default:
+			continue
+		}
+
+		expiring = append(expiring, token)
+		fmt.Printf("%d. %s (%s) on %q, %s, %s\n", len(expiring), token.TokenName, token.TokenLastFour, token.MdxInfo.Name, token.FormattedTokenExpirationDate, state)
+	}
+
+	log.Info("Found expiring cards", "count", len(expiring), "days", *days)
+	if !*renew || len(expiring) == 0 {
+		return nil
+	}
+
+	if !*yes && ask(fmt.Sprintf("Are you sure you want to renew %d cards? (y/n)", len(expiring))) != "y" {
+		return nil
+	}
+
+	if err := stepUp(ctx, client, card); err != nil {
+		return err
+	}
+
+	w, err := NewCardWriter(profile, card, "Renewed")
+	if err != nil {
+		return fmt.Errorf("new card writer: %w", err)
+	}
+	defer w.Close()
+
+	mapping := RenewalMapping{
+		CreatedAt:       now,
+		CardReferenceID: card.CardReferenceID,
+		ExportPath:      w.GetPath(),
+		Renewals:        []Renewal{},
+	}
+	mappingPath := filepath.Join(dir, fmt.Sprintf("%s.json", now.Format("2006_01_02_15_04_05")))
+
+	// Only creation is retried, a failure to record a created card must not
+	// create another one
+	renewed := map[string]api.Token{}
+	var mu sync.Mutex
+
+	failed := 0
+	execute(ctx, expiring, executeOptions{Concurrency: 1, Retries: 2}, func(ctx context.Context, old web.ListedToken) error {
+		token, err := capWeb.RenewToken(ctx, card, old)
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		renewed[old.TokenReferenceID] = token
+		mu.Unlock()
+		return nil
+	}, func(finished int, result executeResult[web.ListedToken]) {
+		old := result.Item
+		progress := fmt.Sprintf("(%d/%d)", finished, len(expiring))
+		if result.Err != nil {
+			failed++
+			log.Error(progress+" Failed to renew card", "card", old.TokenName, "error", result.Err)
+			return
+		}
+
+		mu.Lock()
+		token := renewed[old.TokenReferenceID]
+		mu.Unlock()
+
+		if err := w.Write(token); err != nil {
+			failed++
+			log.Error(progress+" Failed to write renewed card", "card", old.TokenName, "token", token.Token, "error", err)
+			return
+		}
+
+		if token.TokenReferenceID != "" {
+			if err := store.Put(inventory.NewCreatedRecord(card, token, string(api.TokenModeWeb), w.GetBatch(), w.GetPath())); err != nil {
+				log.Error("Failed to record token in inventory", "error", err)
+			}
+		}
+
+		renewal := Renewal{
+			OldTokenReferenceID: old.TokenReferenceID,
+			OldTokenName:        old.TokenName,
+			OldTokenLastFour:    old.TokenLastFour,
+			OldExpirationDate:   old.FormattedTokenExpirationDate,
+			NewTokenReferenceID: token.TokenReferenceID,
+			NewTokenLastFour:    token.LastFour,
+			NewExpirationDate:   token.ExpirationDate,
+			MerchantName:        old.MdxInfo.Name,
+			MerchantURL:         old.MdxInfo.MerchantURL,
+		}
+
+		if *deleteOld {
+			deleteAfter := time.Now().Add(*grace)
+			renewal.DeleteAfter = &deleteAfter
+		}
+
+		mapping.Renewals = append(mapping.Renewals, renewal)
+		if err := saveRenewalMapping(mappingPath, mapping); err != nil {
+			log.Error("Failed to save renewal mapping", "error", err)
+		}
+
+		log.Info(progress+" Renewed card", "card", old.TokenName, "lastFour", token.LastFour)
+	})
+
+	log.Info("Saved renewals", "mapping", mappingPath, "cards", w.GetPath())
+
+	if *deleteOld && *grace <= 0 {
+		if err := deleteRenewed(ctx, capWeb, store, card, dir); err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to renew %d cards", failed)
+	}
+
+	return nil
+}
+
+// deleteRenewed deletes the old cards of every renewal whose grace period has
+// passed and records them as deleted in their mapping file. The old cards are
+// looked up in the live listing first, as deletions send the full token.
+func deleteRenewed(ctx context.Context, capWeb *web.Web, store *inventory.Store, card extension.PaymentCard, dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	// live is only listed once a renewal is due
+	var live map[string]web.ListedToken
+	getLive := func() (map[string]web.ListedToken, error) {
+		if live != nil {
+			return live, nil
+		}
+
+		tokens, err := fetchTokens(ctx, capWeb, card, web.NewTokenQuery())
+		if err != nil {
+			return nil, err
+		}
+
+		live = map[string]web.ListedToken{}
+		for _, token := range tokens {
+			live[token.TokenReferenceID] = token
+		}
+
+		return live, nil
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		var mapping RenewalMapping
+		if err := json.Unmarshal(contents, &mapping); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		if mapping.CardReferenceID != card.CardReferenceID {
+			continue
+		}
+
+		changed := false
+		for i, renewal := range mapping.Renewals {
+			if renewal.OldDeleted || renewal.DeleteAfter == nil || time.Now().Before(*renewal.DeleteAfter) {
+				continue
+			}
+
+			tokens, err := getLive()
+			if err != nil {
+				return err
+			}
+
+			if old, ok := tokens[renewal.OldTokenReferenceID]; ok {
+				if err := capWeb.DeleteToken(ctx, card, old); err != nil {
+					log.Error("Failed to delete renewed card", "card", renewal.OldTokenName, "error", err)
+					continue
+				}
+
+				log.Info("Deleted renewed card", "card", renewal.OldTokenName, "lastFour", renewal.OldTokenLastFour)
+			} else {
+				log.Info("Renewed card was already deleted", "card", renewal.OldTokenName, "lastFour", renewal.OldTokenLastFour)
+			}
+
+			if err := store.MarkDeleted(renewal.OldTokenReferenceID); err != nil {
+				log.Error("Failed to update inventory", "error", err)
+			}
+
+			mapping.Renewals[i].OldDeleted = true
+			changed = true
+		}
+
+		if changed {
+			if err := saveRenewalMapping(path, mapping); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -3,33 +3,111 @@ package main
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/saucesteals/eno/extension"
+	"github.com/saucesteals/eno/inventory"
 	"github.com/saucesteals/eno/web"
 )
 
-func list(ctx context.Context, capWeb *web.Web, card extension.PaymentCard) error {
+func list(ctx context.Context, capWeb *web.Web, store *inventory.Store, card extension.PaymentCard) error {
 	nameFilter := ask("Enter name filter (optional)")
 
-	limit := 50
-	var total int
-	for offset := 0; ; offset += 1 {
-		page, err := capWeb.ListTokens(ctx, card, nameFilter, offset, limit)
+	result, err := store.Sync(ctx, capWeb, card, false)
+	if err != nil {
+		return fmt.Errorf("sync inventory: %w", err)
+	}
+	log.Info("Synced inventory", "updated", result.Updated, "deleted", result.Deleted, "full", result.Full)
+
+	records, err := store.Query(inventory.Query{
+		CardReferenceID: card.CardReferenceID,
+		Name:            nameFilter,
+	})
+	if err != nil {
+		return fmt.Errorf("query inventory: %w", err)
+	}
+
+	for _, record := range records {
+		fmt.Printf("- %q on %q\n", record.TokenName, record.MerchantURL)
+	}
+
+	log.Info("Found cards", "count", len(records))
+
+	return nil
+}
+
+func syncInventory(ctx context.Context, capWeb *web.Web, store *inventory.Store, card extension.PaymentCard) error {
+	full := ask("Full sync? (y/n)") == "y"
+
+	result, err := store.Sync(ctx, capWeb, card, full)
+	if err != nil {
+		return fmt.Errorf("sync inventory: %w", err)
+	}
+
+	log.Info("Synced inventory", "updated", result.Updated, "deleted", result.Deleted, "full", result.Full)
+	return nil
+}
+
+// search queries the local inventory without contacting the server.
+func search(store *inventory.Store, card extension.PaymentCard) error {
+	query := inventory.Query{
+		CardReferenceID: card.CardReferenceID,
+		Name:            ask("Enter name filter (optional)"),
+		Merchant:        ask("Enter merchant filter (optional)"),
+	}
+
+	if statuses := ask("Enter statuses, comma separated (optional)"); statuses != "" {
+		query.Statuses = splitList(statuses)
+	}
+
+	if lastFour := ask("Enter last four digits, comma separated (optional)"); lastFour != "" {
+		query.LastFour = splitList(lastFour)
+	}
+
+	if minDayAge := ask("Enter minimum age in days (optional)"); minDayAge != "" {
+		days, err := strconv.Atoi(minDayAge)
 		if err != nil {
-			return fmt.Errorf("list tokens: %w", err)
+			return fmt.Errorf("invalid minimum day age: %w", err)
 		}
+		query.MinAge = time.Duration(days) * time.Hour * 24
+	}
+
+	query.IncludeDeleted = ask("Include deleted cards? (y/n)") == "y"
 
-		for _, token := range page.Entries {
-			fmt.Printf("- %q on %q\n", token.TokenName, token.MdxInfo.MerchantURL)
+	records, err := store.Query(query)
+	if err != nil {
+		return fmt.Errorf("query inventory: %w", err)
+	}
+
+	for _, record := range records {
+		status := record.DerivedStatus
+		if record.Deleted {
+			status = "DELETED"
 		}
 
-		total += page.Count
-		if page.Count <= total {
-			break
+		fmt.Printf("- %q (%s) on %q, %s, created %s", record.TokenName, record.TokenLastFour, record.MerchantURL, status, record.CreatedAt.Format(time.DateOnly))
+		if record.ExportPath != "" {
+			fmt.Printf(", exported to %s", record.ExportPath)
 		}
+		fmt.Println()
+	}
+
+	lastSync, err := store.LastSync(card)
+	if err != nil {
+		return err
 	}
 
-	log.Info("Found cards", "count", total)
+	log.Info("Found cards", "count", len(records), "lastSync", lastSync.Format(time.DateTime))
+
+	lastFullSync, err := store.LastFullSync(card)
+	if err != nil {
+		return err
+	}
+
+	if time.Since(lastFullSync) > inventory.FullSyncInterval {
+		log.Warn("Cards deleted since the last full sync may still be listed, run a full sync to refresh them", "lastFullSync", lastFullSync.Format(time.DateTime))
+	}
 
 	return nil
 }
@@ -1,13 +1,12 @@
 package main
 
 import (
-	"bufio"
 	"context"
 	"errors"
+	"flag"
 	"fmt"
 	"log/slog"
 	"os"
-	"runtime"
 	"slices"
 	"strconv"
 	"strings"
@@ -15,166 +14,245 @@ import (
 
 	"github.com/lmittmann/tint"
 	"github.com/mattn/go-colorable"
-	http "github.com/saucesteals/fhttp"
 
+	"github.com/saucesteals/eno"
 	"github.com/saucesteals/eno/api"
 	"github.com/saucesteals/eno/extension"
-	"github.com/saucesteals/eno/web"
+	"github.com/saucesteals/eno/otp"
 )
 
 var (
-	log = slog.New(tint.NewHandler(colorable.NewColorable(os.Stdout), &tint.Options{
-		Level:      slog.LevelInfo,
+	// stdin is shared by every prompt, including the terminal OTP provider
+	stdin = otp.NewLineReader(os.Stdin)
+
+	logLevel = new(slog.LevelVar)
+	log      = slog.New(tint.NewHandler(colorable.NewColorable(os.Stdout), &tint.Options{
+		Level:      logLevel,
 		TimeFormat: time.TimeOnly,
 	}))
 )
 
 func main() {
+	oneShot := os.Args[1:]
+
+	// A failed one-shot command exits non-zero so scripts can detect it
+	exitCode := 0
+	fail := func(msg string, args ...any) {
+		log.Error(msg, args...)
+		exitCode = 1
+	}
+
 	defer func() {
 		if r := recover(); r != nil {
-			log.Error("Unexpected error", "error", r)
+			fail("Unexpected error", "error", r)
+		}
+
+		if len(oneShot) == 0 {
+			log.Info("Press ENTER to exit...")
+			stdin.ReadLine(context.Background())
 		}
 
-		log.Info("Press ENTER to exit...")
-		fmt.Scanln()
+		os.Exit(exitCode)
 	}()
 
+	// Global flags come first, a command given after them (e.g. eno --card
+	// 1234 delete --dry-run) is run once instead of starting the interactive
+	// prompt
+	cfg, rest, err := loadConfig(oneShot)
+	if err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fail("Load config", "error", err)
+		}
+		return
+	}
+	oneShot = rest
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	profile, err := loadProfile()
+	programDir = cfg.Home
+	settings, err := cfg.For("")
 	if err != nil {
-		log.Error("Load profile", "error", err)
+		fail("Load config", "error", err)
 		return
 	}
+	settings.apply()
 
-	credentials, err := profile.Credentials.Get()
+	// Profiles are managed without logging in
+	if len(oneShot) > 0 && oneShot[0] == "profile" {
+		if err := profileCommand(cfg, oneShot[1:]); err != nil {
+			fail("Error", "error", err)
+		}
+		return
+	}
+
+	profile, err := loadProfile(cfg.Profile)
 	if err != nil {
-		log.Error("Get credentials", "error", err)
+		fail("Load profile", "error", err)
 		return
 	}
 
-	browserBin := os.Getenv("ENO_BROWSER_BINARY")
-	if browserBin == "" {
-		browserBin = "/Applications/Google Chrome.app/Contents/MacOS/Google Chrome"
-		if runtime.GOOS == "windows" {
-			browserBin = "C:\\Program Files\\Google\\Chrome\\Application\\chrome.exe"
-		}
+	settings, err = cfg.For(profile.Name)
+	if err != nil {
+		fail("Load config", "error", err)
+		return
 	}
+	settings.apply()
 
+	credentials, err := profile.Credentials.Get()
+	if err != nil {
+		fail("Get credentials", "error", err)
+		return
+	}
+
+	browserBin := config.BrowserBinary
 	if _, err := os.Stat(browserBin); err != nil {
 		if os.IsNotExist(err) {
-			log.Error("Please install Google Chrome or set a custom binary with the ENO_BROWSER_BINARY environment variable")
+			fail("Please install Google Chrome or set a custom binary with browser_binary in the config file or the ENO_BROWSER_BINARY environment variable")
 			return
 		}
 
-		log.Error("Check browser binary", "error", err)
+		fail("Check browser binary", "error", err)
 		return
 	}
 
 	userDataDir, err := profile.GetDirectory("user_data")
 	if err != nil {
-		log.Error("Get user data directory", "error", err)
+		fail("Get user data directory", "error", err)
 		return
 	}
 
-	capApi, err := api.New(api.Options{
-		Logger:              log,
-		Credentials:         credentials,
-		BrowserUserDataPath: userDataDir,
-		BrowserBinary:       browserBin,
-	})
+	har, err := newHARRecorder()
 	if err != nil {
-		log.Error("New API", "error", err)
+		fail("New HAR recorder", "error", err)
 		return
 	}
+	defer saveHAR(har)
 
-	device, err := profile.Device.Get()
+	otpProvider, closeOTPProvider, err := newOTPProvider()
 	if err != nil {
-		if !errors.Is(err, ErrResourceMissing) {
-			log.Error("Get device", "error", err)
-			return
-		}
-
-		device = extension.GenerateDevice()
-		err = profile.Device.Set(device)
-		if err != nil {
-			log.Error("Set device", "error", err)
-			return
-		}
+		fail("New OTP provider", "error", err)
+		return
 	}
+	defer closeOTPProvider()
 
-	cookies, err := profile.Cookies.Get()
+	minRemaining, err := minRemainingAttempts()
 	if err != nil {
-		if !errors.Is(err, ErrResourceMissing) {
-			log.Error("Get cookies", "error", err)
-			return
-		}
-
-		cookies = []*http.Cookie{}
-		err = profile.Cookies.Set(cookies)
-		if err != nil {
-			log.Error("Set cookies", "error", err)
-			return
-		}
+		fail("OTP attempts", "error", err)
+		return
 	}
 
-	capApi.SetCookies(cookies)
-
-	capWeb := web.New(capApi)
-
-	capExt, err := extension.New(capApi, device)
+	maxFailures, err := otpMaxFailures()
 	if err != nil {
-		log.Error("New extension", "error", err)
+		fail("OTP attempts", "error", err)
 		return
 	}
 
-	err = login(ctx, profile, capExt, capApi)
+	client, err := eno.New(eno.Options{
+		Credentials:          credentials,
+		Storage:              profile.Profile,
+		Prompter:             newPrompter(otpProvider),
+		BrowserBinary:        browserBin,
+		BrowserUserDataPath:  userDataDir,
+		Logger:               log,
+		HAR:                  har,
+		MinRemainingAttempts: minRemaining,
+		OTPMaxFailures:       maxFailures,
+	})
 	if err != nil {
-		log.Error("Login", "error", err)
+		fail("New client", "error", err)
 		return
 	}
 
-	err = profile.Cookies.Set(capApi.GetCookies())
+	err = client.Login(ctx)
 	if err != nil {
-		log.Error("Save cookies after login", "error", err)
+		fail("Login", "error", err)
 		return
 	}
 
+	capWeb, capExt := client.Web, client.Extension
+	capWeb.SetPageSize(config.PageSize)
+
 	cards, err := capExt.GetPaymentCards(ctx)
 	if err != nil {
-		log.Error("Get payment cards", "error", err)
+		fail("Get payment cards", "error", err)
 		return
 	}
 
 	commands := []string{
 		"create",
+		"merchant",
 		"list",
+		"search",
+		"sync",
+		"statement",
+		"report",
+		"reconcile",
 		"delete",
+		"lock",
+		"unlock",
+		"rename",
+		"bind",
+		"unbind",
+		"revert",
+		"cleanup",
+		"expiry",
+		"status",
 		"exit",
 	}
 
 	for {
 		var command string
+		var args []string
 		for {
-			command = ask(fmt.Sprintf("Enter command (%s)", strings.Join(commands, ", ")))
-			if slices.Contains(commands, command) {
+			fields := oneShot
+			if len(oneShot) == 0 {
+				var err error
+				fields, err = splitArgs(ask(fmt.Sprintf("Enter command (%s)", strings.Join(commands, ", "))))
+				if err != nil {
+					fail("Invalid command", "error", err)
+					continue
+				}
+			}
+
+			if len(fields) > 0 && slices.Contains(commands, fields[0]) {
+				command, args = fields[0], fields[1:]
 				break
 			}
 
-			log.Error("Invalid command", "command", command)
+			fail("Invalid command", "command", strings.Join(fields, " "))
+			if len(oneShot) > 0 {
+				return
+			}
 		}
 
 		if command == "exit" {
 			log.Info("Exiting...")
+			saveHAR(har)
 			os.Exit(0)
 			return
 		}
 
+		if command == "cleanup" {
+			if err := cleanup(ctx, profile, client, cards, args); err != nil {
+				fail("Error", "error", err)
+			}
+
+			if len(oneShot) > 0 {
+				return
+			}
+			continue
+		}
+
 		var card extension.PaymentCard
 		if len(cards) == 0 {
-			log.Error("No cards found")
+			fail("No cards found")
+			if len(oneShot) > 0 {
+				return
+			}
 			continue
+		} else if i := findCard(cards, config.DefaultCard); i >= 0 {
+			card = cards[i]
 		} else if len(cards) == 1 {
 			card = cards[0]
 		} else {
@@ -185,14 +263,20 @@ func main() {
 
 			cardIndex, err := strconv.Atoi(ask("Select a card"))
 			if err != nil {
-				log.Error("Invalid card number")
+				fail("Invalid card number")
+				if len(oneShot) > 0 {
+					return
+				}
 				continue
 			}
 
 			cardIndex--
 
-			if cardIndex < 0 || cardIndex > len(cards) {
-				log.Error("Unknown card")
+			if cardIndex < 0 || cardIndex >= len(cards) {
+				fail("Unknown card")
+				if len(oneShot) > 0 {
+					return
+				}
 				continue
 			}
 
@@ -201,30 +285,92 @@ func main() {
 
 		fmt.Printf("Selected card: %s (%s)\n", card.CardNumber, card.ProductDescription)
 
-		var err error
+		// The inventory is opened per command so other eno processes can use
+		// the profile while this one waits for input
+		store, err := profile.OpenInventory()
+		if err != nil {
+			fail("Open inventory", "error", err)
+			if len(oneShot) > 0 {
+				return
+			}
+			continue
+		}
+
 		switch command {
 		case "list":
-			err = list(ctx, capWeb, card)
+			err = list(ctx, capWeb, store, card)
+		case "search":
+			err = search(store, card)
+		case "sync":
+			err = syncInventory(ctx, capWeb, store, card)
+		case "statement":
+			err = importStatement(store, card, args)
+		case "report":
+			err = report(ctx, capWeb, store, card, args)
+		case "reconcile":
+			err = reconcile(ctx, profile, capWeb, card, args)
 		case "delete":
-			err = delete(ctx, capWeb, card)
+			err = delete(ctx, profile, capWeb, store, card, args)
 		case "create":
-			err = create(ctx, profile, capWeb, capExt, card)
+			err = create(ctx, profile, client, store, card)
+		case "merchant":
+			err = merchant(ctx, profile, capWeb, capExt, store, card, args)
+		case "lock":
+			err = lock(ctx, profile, capWeb, card)
+		case "unlock":
+			err = unlock(ctx, profile, capWeb, card)
+		case "rename":
+			err = rename(ctx, profile, capWeb, card)
+		case "bind":
+			err = bind(ctx, profile, capWeb, capExt, card)
+		case "unbind":
+			err = unbind(ctx, profile, capWeb, card)
+		case "revert":
+			err = revert(ctx, profile, capWeb, card)
+		case "expiry":
+			err = expiry(ctx, profile, client, store, card, args)
+		case "status":
+			err = stepUpStatus(ctx, capWeb, card)
 		}
+		store.Close()
 
 		if err != nil {
-			log.Error("Error", "error", err)
+			fail("Error", "error", err)
+		}
+
+		if len(oneShot) > 0 {
+			return
 		}
 	}
 }
+
 func ask(prompt string) string {
 	fmt.Printf("[?] %s: ", prompt)
-	reader := bufio.NewReader(os.Stdin)
-	input, _ := reader.ReadString('\n')
-	return strings.TrimSpace(input)
+	input, _ := stdin.ReadLine(context.Background())
+	return input
 }
 
-func loadProfile() (*Profile, error) {
-	username := ask("Enter username")
+// findCard returns the index of the card whose number ends with or whose
+// description contains query, or -1.
+func findCard(cards []extension.PaymentCard, query string) int {
+	if query == "" {
+		return -1
+	}
+
+	i := slices.IndexFunc(cards, func(card extension.PaymentCard) bool {
+		return strings.HasSuffix(card.CardNumber, query) || strings.Contains(strings.ToLower(card.ProductDescription), strings.ToLower(query))
+	})
+	if i < 0 {
+		log.Error("Default card not found", "card", query)
+	}
+
+	return i
+}
+
+func loadProfile(username string) (*Profile, error) {
+	if username == "" {
+		username = ask("Enter username")
+	}
 
 	profile, err := ImportProfile(username)
 	if err != nil {
@@ -250,3 +396,36 @@ func loadProfile() (*Profile, error) {
 
 	return profile, nil
 }
+
+func newHARRecorder() (*api.HARRecorder, error) {
+	if os.Getenv("ENO_HAR_FILE") == "" {
+		return nil, nil
+	}
+
+	redaction := api.DefaultHARRedaction()
+	switch policy := os.Getenv("ENO_HAR_REDACTION"); policy {
+	case "", "default":
+	case "strict":
+		redaction.OmitCleartext = true
+	case "none":
+		redaction = api.HARRedaction{}
+	default:
+		return nil, fmt.Errorf("unknown redaction policy: %s", policy)
+	}
+
+	return api.NewHARRecorder(redaction), nil
+}
+
+func saveHAR(har *api.HARRecorder) {
+	if har == nil {
+		return
+	}
+
+	path := os.Getenv("ENO_HAR_FILE")
+	if err := har.Save(path); err != nil {
+		log.Error("Save HAR", "error", err)
+		return
+	}
+
+	log.Info("Saved HAR", "path", path)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"iter"
+
+	"github.com/saucesteals/eno/api"
+	"github.com/saucesteals/eno/extension"
+	"github.com/saucesteals/eno/inventory"
+	"github.com/saucesteals/eno/web"
+)
+
+// merchant prints the card for a merchant, creating and exporting one if
+// there is none yet.
+func merchant(ctx context.Context, profile *Profile, capWeb *web.Web, capExt *extension.Extension, store *inventory.Store, card extension.PaymentCard, args []string) error {
+	fs := flag.NewFlagSet("merchant", flag.ContinueOnError)
+	merchantURL := fs.String("url", "", "merchant `URL` (e.g. www.netflix.com)")
+	name := fs.String("name", "", "`name` of the card if one is created (default \"<merchant> Card\")")
+
+	if ok, err := parseFlags(fs, args); !ok {
+		return err
+	}
+
+	var m *api.Merchant
+	var err error
+	if *merchantURL == "" {
+		m, err = askMerchant(ctx, capExt, "Enter merchant URL (e.g. www.google.com)", false)
+	} else {
+		m, err = findMerchant(ctx, capExt, *merchantURL)
+	}
+	if err != nil {
+		return err
+	}
+
+	tokens := func(query web.TokenQuery) iter.Seq2[web.ListedToken, error] {
+		return capWeb.Tokens(ctx, card, query)
+	}
+	result, err := store.GetOrCreateForMerchant(ctx, tokens, capExt, card, *m, *name)
+	if err != nil {
+		return err
+	}
+
+	if !result.Created {
+		log.Info("Found card for merchant", "merchant", result.Merchant.Name, "card", result.TokenName, "lastFour", result.TokenLastFour)
+		return nil
+	}
+
+	w, err := NewCardWriter(profile, card, result.Merchant.Name)
+	if err != nil {
+		return fmt.Errorf("new card writer: %w", err)
+	}
+	defer w.Close()
+
+	if err := w.Write(*result.Token); err != nil {
+		return fmt.Errorf("write token: %w", err)
+	}
+
+	if result.TokenReferenceID != "" {
+		result.Record.Batch = w.GetBatch()
+		result.Record.ExportPath = w.GetPath()
+		if err := store.Put(result.Record); err != nil {
+			log.Error("Failed to record token in inventory", "error", err)
+		}
+	}
+
+	log.Info("Created card for merchant", "merchant", result.Merchant.Name, "card", result.TokenName, "token", result.Token.Token, "path", w.GetPath())
+	return nil
+}
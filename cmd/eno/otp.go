@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/saucesteals/eno/otp"
+)
+
+// newOTPProvider builds the provider configured through ENO_OTP_PROVIDER:
+//
+//	terminal (default)
+//	file:<path to file or fifo>
+//	command:<program> [args...]
+//	http:<listen address>
+func newOTPProvider() (otp.Provider, func(), error) {
+	noop := func() {}
+	spec := os.Getenv("ENO_OTP_PROVIDER")
+	kind, arg, _ := strings.Cut(spec, ":")
+
+	policy := otp.Policy{Resends: 2}
+	if kind != "" && kind != "terminal" {
+		policy.Timeout = time.Minute * 5
+	}
+
+	if timeout := os.Getenv("ENO_OTP_TIMEOUT"); timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			return nil, noop, fmt.Errorf("invalid ENO_OTP_TIMEOUT: %w", err)
+		}
+		policy.Timeout = d
+	}
+
+	if resends := os.Getenv("ENO_OTP_RESENDS"); resends != "" {
+		n, err := strconv.Atoi(resends)
+		if err != nil {
+			return nil, noop, fmt.Errorf("invalid ENO_OTP_RESENDS: %w", err)
+		}
+		policy.Resends = n
+	}
+
+	pattern := otp.DefaultPattern
+	if p := os.Getenv("ENO_OTP_PATTERN"); p != "" {
+		var err error
+		pattern, err = regexp.Compile(p)
+		if err != nil {
+			return nil, noop, fmt.Errorf("invalid ENO_OTP_PATTERN: %w", err)
+		}
+	}
+
+	switch kind {
+	case "", "terminal":
+		return otp.NewTerminal(stdin, os.Stdout, policy), noop, nil
+	case "file":
+		return otp.NewFile(arg, pattern, policy), noop, nil
+	case "command":
+		fields := strings.Fields(arg)
+		if len(fields) == 0 {
+			return nil, noop, fmt.Errorf("missing otp command")
+		}
+		return otp.NewCommand(fields[0], fields[1:], pattern, policy), noop, nil
+	case "http":
+		listener, err := otp.NewHTTPListener(arg, pattern, policy)
+		if err != nil {
+			return nil, noop, fmt.Errorf("listen for otp: %w", err)
+		}
+		log.Info("Listening for forwarded OTP messages", "addr", listener.Addr())
+		return listener, func() { listener.Close() }, nil
+	default:
+		return nil, noop, fmt.Errorf("unknown otp provider: %s", kind)
+	}
+}
+
+// selectDestination adapts selectContactPoint to otp.Selector.
+func selectDestination(ctx context.Context, destinations []otp.Destination) (int, error) {
+	labels := []string{}
+	for _, destination := range destinations {
+		labels = append(labels, destination.Label)
+	}
+
+	return selectContactPoint(labels)
+}
+
+// minRemainingAttempts is how many OTP attempts are left untouched so a wrong
+// code never locks the profile.
+func minRemainingAttempts() (int, error) {
+	value := os.Getenv("ENO_OTP_MIN_REMAINING_ATTEMPTS")
+	if value == "" {
+		return 1, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ENO_OTP_MIN_REMAINING_ATTEMPTS: %w", err)
+	}
+
+	return n, nil
+}
+
+// otpMaxFailures is how many wrong login codes lock the profile. The login
+// challenge does not report it, so wrong login codes are only retried when it
+// is set.
+func otpMaxFailures() (int, error) {
+	value := os.Getenv("ENO_OTP_MAX_FAILURES")
+	if value == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid ENO_OTP_MAX_FAILURES: %q", value)
+	}
+
+	return n, nil
+}
+
+// selectContactPoint picks the contact point matching ENO_OTP_CONTACT_POINT
+// for unattended runs, or asks for one otherwise.
+func selectContactPoint(contactPoints []string) (int, error) {
+	if len(contactPoints) == 0 {
+		return 0, fmt.Errorf("no contact points found")
+	}
+
+	if preferred := os.Getenv("ENO_OTP_CONTACT_POINT"); preferred != "" {
+		for i, contactPoint := range contactPoints {
+			if strings.Contains(contactPoint, preferred) {
+				return i, nil
+			}
+		}
+
+		return 0, fmt.Errorf("no contact point matching %q", preferred)
+	}
+
+	fmt.Printf("Contact Points:\n")
+	for i, contactPoint := range contactPoints {
+		fmt.Printf("%d. %s\n", i+1, contactPoint)
+	}
+
+	contactPointIndex, err := strconv.Atoi(ask("Select a contact point"))
+	if err != nil {
+		return 0, fmt.Errorf("invalid contact point")
+	}
+
+	contactPointIndex--
+	if contactPointIndex < 0 || contactPointIndex >= len(contactPoints) {
+		return 0, fmt.Errorf("unknown contact point")
+	}
+
+	return contactPointIndex, nil
+}
@@ -1,108 +1,40 @@
 package main
 
 import (
-	"encoding/json"
-	"errors"
-	"fmt"
 	"os"
 	"path/filepath"
-	"sync"
-
-	http "github.com/saucesteals/fhttp"
 
 	"github.com/saucesteals/eno/api"
-	"github.com/saucesteals/eno/extension"
+	"github.com/saucesteals/eno/inventory"
+	"github.com/saucesteals/eno/profile"
 )
 
 var (
-	ErrResourceMissing = errors.New("resource missing")
-)
-
-type Resource[T any] struct {
-	mu       sync.Mutex
-	data     T
-	isLoaded bool
-	path     string
-}
-
-func NewResource[T any](path string) *Resource[T] {
-	return &Resource[T]{
-		path: path,
-	}
-}
-
-func (r *Resource[T]) load() error {
-	contents, err := os.ReadFile(r.path)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return fmt.Errorf("%s: %w", r.path, ErrResourceMissing)
-		}
-
-		return err
-	}
-
-	err = json.Unmarshal(contents, &r.data)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (r *Resource[T]) save() error {
-	contents, err := json.MarshalIndent(r.data, "", "  ")
-	if err != nil {
-		return err
-	}
+	ErrResourceMissing = profile.ErrNotFound
 
-	err = os.WriteFile(r.path, contents, 0600)
-	if err != nil {
-		return err
-	}
+	// programDir overrides the default ~/eno
+	programDir string
+)
 
-	return nil
-}
+// Profile adds the documents only the cli uses to a saved profile.
+type Profile struct {
+	*profile.Profile
 
-func (r *Resource[T]) Set(data T) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	r.data = data
-	r.isLoaded = true
-	return r.save()
+	Credentials *profile.Resource[api.Credentials]
+	Policies    *profile.Resource[[]CleanupPolicy]
 }
 
-func (r *Resource[T]) Get() (T, error) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	if !r.isLoaded {
-		err := r.load()
+func GetProgramDir(subfolders ...string) (string, error) {
+	base := programDir
+	if base == "" {
+		userHome, err := os.UserHomeDir()
 		if err != nil {
-			return r.data, err
+			return "", err
 		}
 
-		r.isLoaded = true
+		base = filepath.Join(userHome, "eno")
 	}
 
-	return r.data, nil
-}
-
-type Profile struct {
-	path string
-
-	Credentials *Resource[api.Credentials]
-	Device      *Resource[extension.Device]
-	Cookies     *Resource[[]*http.Cookie]
-	Express     *Resource[extension.ExpressEnrollment]
-}
-
-func GetProgramDir(subfolders ...string) (string, error) {
-	userHome, err := os.UserHomeDir()
-	if err != nil {
-		return "", err
-	}
-
-	base := filepath.Join(userHome, "eno")
 	for _, subfolder := range subfolders {
 		base = filepath.Join(base, subfolder)
 	}
@@ -127,27 +59,18 @@ func ImportProfile(username string) (*Profile, error) {
 		return nil, err
 	}
 
+	p, err := profile.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Profile{
-		path:        dir,
-		Credentials: NewResource[api.Credentials](filepath.Join(dir, "credentials.json")),
-		Device:      NewResource[extension.Device](filepath.Join(dir, "device.json")),
-		Cookies:     NewResource[[]*http.Cookie](filepath.Join(dir, "cookies.json")),
-		Express:     NewResource[extension.ExpressEnrollment](filepath.Join(dir, "express.json")),
+		Profile:     p,
+		Credentials: profile.NewResource[api.Credentials](p, profile.Credentials),
+		Policies:    profile.NewResource[[]CleanupPolicy](p, "policies"),
 	}, nil
 }
 
-func (p *Profile) GetDirectory(parts ...string) (string, error) {
-	dir := filepath.Join(p.path, filepath.Join(parts...))
-	if _, err := os.Stat(dir); err != nil {
-		if !os.IsNotExist(err) {
-			return "", err
-		}
-
-		err = os.MkdirAll(dir, 0700)
-		if err != nil {
-			return "", err
-		}
-	}
-
-	return dir, nil
+func (p *Profile) OpenInventory() (*inventory.Store, error) {
+	return inventory.Open(filepath.Join(p.Dir, "inventory.db"))
 }
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	http "github.com/saucesteals/fhttp"
+
+	"github.com/saucesteals/eno/api"
+	"github.com/saucesteals/eno/extension"
+	"github.com/saucesteals/eno/profile"
+	"github.com/saucesteals/eno/web"
+)
+
+var profileCommands = []string{"list", "show", "delete", "reset-session", "reset-device", "rename", "export", "import"}
+
+// profileCommand manages the saved profiles. It runs without logging in.
+func profileCommand(cfg *Config, args []string) error {
+	if len(args) == 0 || !slices.Contains(profileCommands, args[0]) {
+		return fmt.Errorf("usage: eno profile (%s)", strings.Join(profileCommands, "|"))
+	}
+
+	command, args := args[0], args[1:]
+	switch command {
+	case "export":
+		return exportProfile(cfg, args)
+	case "import":
+		return importProfile(args)
+	}
+
+	fs := flag.NewFlagSet("profile "+command, flag.ContinueOnError)
+	yes := fs.Bool("yes", false, "do not ask for confirmation")
+
+	names, ok, err := parseArgs(fs, args)
+	if !ok {
+		return err
+	}
+
+	want := 1
+	switch command {
+	case "list":
+		want = 0
+	case "rename":
+		want = 2
+	}
+
+	if len(names) != want {
+		return fmt.Errorf("%s expects %d profile names, got %d", command, want, len(names))
+	}
+
+	switch command {
+	case "list":
+		return listProfiles()
+	case "show":
+		settings, err := cfg.For(names[0])
+		if err != nil {
+			return err
+		}
+		settings.apply()
+
+		return showProfile(names[0])
+	case "delete":
+		settings, err := cfg.For(names[0])
+		if err != nil {
+			return err
+		}
+		settings.apply()
+
+		return deleteProfile(names[0], *yes)
+	case "reset-session":
+		return resetProfile(names[0], *yes, "session", profile.Cookies, profile.StepUps)
+	case "reset-device":
+		// A new device has to log in, complete the OTP challenge and enroll
+		// again, so everything tied to the old one goes too
+		return resetProfile(names[0], *yes, "device", profile.Device, profile.Express, profile.Cookies, profile.StepUps)
+	case "rename":
+		return renameProfile(cfg, names[0], names[1])
+	}
+
+	return nil
+}
+
+func validateProfileName(name string) error {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("invalid profile name: %q", name)
+	}
+
+	return nil
+}
+
+// findProfile returns the directory of an existing profile.
+func findProfile(name string) (string, error) {
+	if err := validateProfileName(name); err != nil {
+		return "", err
+	}
+
+	root, err := GetProgramDir("profiles")
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Join(root, name)
+	if _, err := os.Stat(dir); err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("profile %s does not exist", name)
+		}
+
+		return "", err
+	}
+
+	return dir, nil
+}
+
+func listProfiles() error {
+	root, err := GetProgramDir("profiles")
+	if err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return err
+	}
+
+	count := 0
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		count++
+		fmt.Printf("%s (session %s)\n", entry.Name(), sessionAge(filepath.Join(root, entry.Name())))
+	}
+
+	log.Info("Found profiles", "count", count, "path", root)
+	return nil
+}
+
+// sessionAge describes when the session cookies were last saved.
+func sessionAge(dir string) string {
+	info, err := os.Stat(filepath.Join(dir, profile.Cookies+".json"))
+	if err != nil {
+		return "missing"
+	}
+
+	return fmt.Sprintf("saved %s ago", time.Since(info.ModTime()).Round(time.Minute))
+}
+
+// showProfile prints what is saved in a profile, leaving out passwords,
+// cookies, including those of step-ups, and tokens.
+func showProfile(name string) error {
+	dir, err := findProfile(name)
+	if err != nil {
+		return err
+	}
+
+	p, err := profile.Open(dir)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Profile: %s\n", name)
+	fmt.Printf("Path: %s\n", dir)
+
+	var credentials api.Credentials
+	if err := loadDocument(p, profile.Credentials, &credentials); err == nil {
+		fmt.Printf("Username: %s (password saved: %t)\n", credentials.Username, credentials.Password != "")
+	}
+
+	var cookies []*http.Cookie
+	if err := loadDocument(p, profile.Cookies, &cookies); err == nil {
+		fmt.Printf("Session: %d cookies, %s\n", len(cookies), sessionAge(dir))
+	}
+
+	var stepUps []web.StepUpAuthorization
+	if err := loadDocument(p, profile.StepUps, &stepUps); err == nil {
+		for _, stepUp := range stepUps {
+			state := "expired"
+			if stepUp.Valid() {
+				state = "valid until " + stepUp.ExpiresAt.Local().Format(time.DateTime)
+			}
+			fmt.Printf("Step-up: card %s, %d cookies, %s\n", stepUp.CardReferenceID, len(stepUp.Cookies), state)
+		}
+	}
+
+	var express extension.ExpressEnrollment
+	if err := loadDocument(p, profile.Express, &express); err == nil {
+		fmt.Printf("Express: enrolled %t\n", express.ExpressCheckoutToken != "")
+	}
+
+	var device extension.Device
+	if err := loadDocument(p, profile.Device, &device); err == nil {
+		fmt.Printf("Device: extension %s, auth transaction %s\n", device.ExtensionId, device.AuthTransactionId)
+	}
+
+	cardsDir := exportRoot(name, dir)
+	entries, err := os.ReadDir(cardsDir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		files, cards, err := countExports(filepath.Join(cardsDir, entry.Name()))
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Exports: %s, %d cards in %d files\n", entry.Name(), cards, files)
+	}
+
+	return nil
+}
+
+// loadDocument peeks at a document for showProfile without migrating the
+// saved copy, printing why it could not be loaded.
+func loadDocument(p *profile.Profile, name string, v any) error {
+	err := p.Peek(name, v)
+	if errors.Is(err, profile.ErrNotFound) {
+		fmt.Printf("%s: missing\n", name)
+	} else if err != nil {
+		fmt.Printf("%s: %s\n", name, err)
+	}
+
+	return err
+}
+
+func countExports(dir string) (files int, cards int, err error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".csv" {
+			continue
+		}
+
+		contents, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return 0, 0, err
+		}
+
+		files++
+		for _, line := range strings.Split(string(contents), "\n") {
+			if strings.TrimSpace(line) != "" {
+				cards++
+			}
+		}
+	}
+
+	return files, cards, nil
+}
+
+func deleteProfile(name string, yes bool) error {
+	dir, err := findProfile(name)
+	if err != nil {
+		return err
+	}
+
+	if !yes && ask(fmt.Sprintf("Are you sure you want to delete profile %s and its card exports? (y/n)", name)) != "y" {
+		return nil
+	}
+
+	// The exports live outside the profile when export_dir is set
+	if err := os.RemoveAll(exportRoot(name, dir)); err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+
+	log.Info("Deleted profile", "profile", name)
+	return nil
+}
+
+// profileExportRoots returns where the card exports of a profile are before
+// and after it is renamed, as each name may have its own export_dir.
+func profileExportRoots(cfg *Config, name string, dir string, newName string, newDir string) (string, string, error) {
+	settings, err := cfg.For(name)
+	if err != nil {
+		return "", "", err
+	}
+	settings.apply()
+
+	exportsDir := exportRoot(name, dir)
+
+	settings, err = cfg.For(newName)
+	if err != nil {
+		return "", "", err
+	}
+	settings.apply()
+
+	return exportsDir, exportRoot(newName, newDir), nil
+}
+
+func resetProfile(name string, yes bool, what string, documents ...string) error {
+	dir, err := findProfile(name)
+	if err != nil {
+		return err
+	}
+
+	if !yes && ask(fmt.Sprintf("Are you sure you want to reset the %s of profile %s? (y/n)", what, name)) != "y" {
+		return nil
+	}
+
+	p, err := profile.Open(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, document := range documents {
+		if err := p.Delete(document); err != nil {
+			return fmt.Errorf("delete %s: %w", document, err)
+		}
+	}
+
+	log.Info("Reset profile", "profile", name, "reset", what)
+	return nil
+}
+
+func renameProfile(cfg *Config, name string, newName string) error {
+	dir, err := findProfile(name)
+	if err != nil {
+		return err
+	}
+
+	if err := validateProfileName(newName); err != nil {
+		return err
+	}
+
+	newDir := filepath.Join(filepath.Dir(dir), newName)
+	if _, err := os.Stat(newDir); err == nil {
+		return fmt.Errorf("profile %s already exists", newName)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	exportsDir, newExportsDir, err := profileExportRoots(cfg, name, dir, newName, newDir)
+	if err != nil {
+		return err
+	}
+
+	// Exports inside the profile move with it
+	movedExportsDir := exportsDir
+	if rel, err := filepath.Rel(dir, exportsDir); err == nil && filepath.IsLocal(rel) {
+		movedExportsDir = filepath.Join(newDir, rel)
+	}
+
+	moveExports := movedExportsDir != newExportsDir
+	if moveExports {
+		if _, err := os.Stat(exportsDir); os.IsNotExist(err) {
+			moveExports = false
+		} else if err != nil {
+			return err
+		}
+	}
+
+	if moveExports {
+		if _, err := os.Stat(newExportsDir); err == nil {
+			return fmt.Errorf("card exports already exist at %s", newExportsDir)
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	if err := os.Rename(dir, newDir); err != nil {
+		return err
+	}
+
+	if moveExports {
+		if err := os.MkdirAll(filepath.Dir(newExportsDir), 0700); err != nil {
+			return err
+		}
+
+		if err := os.Rename(movedExportsDir, newExportsDir); err != nil {
+			return fmt.Errorf("move card exports: %w", err)
+		}
+	}
+
+	log.Info("Renamed profile", "from", name, "to", newName)
+	return nil
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/saucesteals/eno"
+	"github.com/saucesteals/eno/extension"
+	"github.com/saucesteals/eno/otp"
+)
+
+func newPrompter(otpProvider otp.Provider) eno.Prompter {
+	return eno.Callbacks{
+		OTP:    otpProvider,
+		Select: selectDestination,
+		GetCVV: func(ctx context.Context, card extension.PaymentCard) (string, error) {
+			return ask(fmt.Sprintf("Enter CVV for card %s (%s)", card.CardNumber, card.ProductDescription)), nil
+		},
+	}
+}
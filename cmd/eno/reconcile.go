@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/saucesteals/eno/api"
+	"github.com/saucesteals/eno/extension"
+	"github.com/saucesteals/eno/web"
+)
+
+// exportPrefix returns the cleaned card name prefix of an export file named
+// <timestamp>_<prefix>.csv, or <timestamp>_<prefix>_<mode>.csv for create.
+func exportPrefix(path string) string {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	timestamp := len("2006_01_02_15_04_05_")
+	if len(name) <= timestamp {
+		return ""
+	}
+
+	prefix := name[timestamp:]
+	for _, mode := range []api.TokenMode{api.TokenModeWeb, api.TokenModeExtension, api.TokenModeAuto} {
+		if trimmed, ok := strings.CutSuffix(prefix, "_"+string(mode)); ok {
+			return trimmed
+		}
+	}
+
+	return prefix
+}
+
+// matchExport finds the listed token an exported card belongs to by last
+// four digits and expiration date, using the export's name as a tie-breaker.
+func matchExport(exported ExportedCard, tokens []web.ListedToken, used map[string]bool) (web.ListedToken, bool) {
+	expiresAt := time.Date(exported.ExpYear, time.Month(exported.ExpMonth)+1, 1, 0, 0, 0, 0, time.Local)
+	prefix := exportPrefix(exported.Path)
+
+	var match *web.ListedToken
+	for i, token := range tokens {
+		if used[token.TokenReferenceID] || token.TokenLastFour != exported.LastFour() {
+			continue
+		}
+
+		if tokenExpiresAt, err := token.ExpiresAt(); err == nil && !tokenExpiresAt.Equal(expiresAt) {
+			continue
+		}
+
+		if match == nil || (prefix != "" && strings.HasPrefix(cleanName(token.TokenName), prefix)) {
+			match = &tokens[i]
+		}
+	}
+
+	if match == nil {
+		return web.ListedToken{}, false
+	}
+
+	return *match, true
+}
+
+// reconcile compares the exported card files with the cards on the server.
+func reconcile(ctx context.Context, profile *Profile, capWeb *web.Web, card extension.PaymentCard, args []string) error {
+	fs := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	writeActive := fs.Bool("write-active", false, "write the cards that still exist to a new active cards export")
+
+	if ok, err := parseFlags(fs, args); !ok {
+		return err
+	}
+
+	exported, err := ReadCardExports(profile, card)
+	if err != nil {
+		return fmt.Errorf("read card exports: %w", err)
+	}
+
+	tokens, err := fetchTokens(ctx, capWeb, card, web.NewTokenQuery())
+	if err != nil {
+		return err
+	}
+
+	used := map[string]bool{}
+	active := []ExportedCard{}
+	expired := 0
+	deleted := 0
+
+	for _, exportedCard := range exported {
+		token, ok := matchExport(exportedCard, tokens, used)
+		if !ok {
+			deleted++
+			fmt.Printf("- deleted: %s %02d/%d from %s\n", exportedCard.LastFour(), exportedCard.ExpMonth, exportedCard.ExpYear, filepath.Base(exportedCard.Path))
+			continue
+		}
+
+		used[token.TokenReferenceID] = true
+		if token.Expired() {
+			expired++
+			fmt.Printf("- expired: %s (%s) from %s\n", token.TokenName, token.TokenLastFour, filepath.Base(exportedCard.Path))
+			continue
+		}
+
+		active = append(active, exportedCard)
+	}
+
+	missing := 0
+	for _, token := range tokens {
+		if used[token.TokenReferenceID] {
+			continue
+		}
+
+		missing++
+		fmt.Printf("- missing locally: %s (%s) %s\n", token.TokenName, token.TokenLastFour, token.DerivedStatus)
+	}
+
+	log.Info("Reconciled card exports", "exported", len(exported), "active", len(active), "expired", expired, "deleted", deleted, "missingLocally", missing)
+
+	if !*writeActive || len(active) == 0 {
+		return nil
+	}
+
+	// The active cards are a copy of other exports, kept out of the card
+	// directory so ReadCardExports does not read them twice
+	dir, err := getCardDirectory(profile, card)
+	if err != nil {
+		return err
+	}
+
+	dir = filepath.Join(dir, "active")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	w, err := newCardWriter(dir, "Active")
+	if err != nil {
+		return fmt.Errorf("new card writer: %w", err)
+	}
+	defer w.Close()
+
+	for _, exportedCard := range active {
+		if err := w.Write(exportedCard.Token()); err != nil {
+			return fmt.Errorf("write token: %w", err)
+		}
+	}
+
+	log.Info("Saved active cards", "count", len(active), "path", w.GetPath())
+	return nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/saucesteals/eno/extension"
+	"github.com/saucesteals/eno/inventory"
+	"github.com/saucesteals/eno/web"
+)
+
+// report summarizes the cards of the inventory after syncing it with the
+// server.
+func report(ctx context.Context, capWeb *web.Web, store *inventory.Store, card extension.PaymentCard, args []string) error {
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	format := fs.String("format", config.OutputFormat, "output `format` (table, csv or json)")
+	expiringDays := fs.Int("expiring-days", 30, "list cards expiring within `days`")
+	output := fs.String("output", "", "write the report to `path` instead of the terminal")
+	offline := fs.Bool("offline", false, "use the inventory without syncing it first")
+
+	if ok, err := parseFlags(fs, args); !ok {
+		return err
+	}
+
+	if *format != "table" && *format != "csv" && *format != "json" {
+		return fmt.Errorf("unknown format: %s", *format)
+	}
+
+	if !*offline {
+		if _, err := store.Sync(ctx, capWeb, card, false); err != nil {
+			return fmt.Errorf("sync inventory: %w", err)
+		}
+	}
+
+	records, err := store.Query(inventory.Query{CardReferenceID: card.CardReferenceID, IncludeDeleted: true})
+	if err != nil {
+		return fmt.Errorf("query inventory: %w", err)
+	}
+
+	r := inventory.NewReport(records, time.Now(), time.Duration(*expiringDays)*time.Hour*24)
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.OpenFile(*output, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	switch *format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(r)
+	case "csv":
+		err = writeReportCSV(w, r)
+	default:
+		err = writeReportTable(w, r)
+	}
+	if err != nil {
+		return err
+	}
+
+	if *output != "" {
+		log.Info("Saved report", "path", *output)
+	}
+
+	return nil
+}
+
+func writeReportTable(w io.Writer, r inventory.Report) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "Cards\t%d\n", r.Total)
+	fmt.Fprintf(tw, "Deleted\t%d\n", r.Deleted)
+	for _, breakdown := range r.Breakdowns {
+		fmt.Fprintf(tw, "\nBy %s\t\n", breakdown.Name)
+		for _, count := range breakdown.Counts {
+			fmt.Fprintf(tw, "  %s\t%d\n", count.Key, count.Count)
+		}
+	}
+
+	fmt.Fprintf(tw, "\nExpiring soon\t%d\n", len(r.Expiring))
+	for _, record := range r.Expiring {
+		fmt.Fprintf(tw, "  %s (%s)\t%s\n", record.TokenName, record.TokenLastFour, record.ExpirationDate)
+	}
+
+	return tw.Flush()
+}
+
+// writeReportCSV writes one row per count, followed by the expiring cards.
+func writeReportCSV(w io.Writer, r inventory.Report) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"group", "key", "count"})
+	cw.Write([]string{"total", "", strconv.Itoa(r.Total)})
+	cw.Write([]string{"deleted", "", strconv.Itoa(r.Deleted)})
+
+	for _, breakdown := range r.Breakdowns {
+		for _, count := range breakdown.Counts {
+			cw.Write([]string{breakdown.Name, count.Key, strconv.Itoa(count.Count)})
+		}
+	}
+
+	for _, record := range r.Expiring {
+		cw.Write([]string{"expiring", fmt.Sprintf("%s (%s) %s", record.TokenName, record.TokenLastFour, record.ExpirationDate), "1"})
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
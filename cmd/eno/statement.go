@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/saucesteals/eno/extension"
+	"github.com/saucesteals/eno/inventory"
+	"github.com/saucesteals/eno/statement"
+)
+
+// importStatement attributes the transactions of downloaded statement exports
+// to virtual cards from the inventory, offline.
+func importStatement(store *inventory.Store, card extension.PaymentCard, args []string) error {
+	fs := flag.NewFlagSet("statement", flag.ContinueOnError)
+	files := fs.String("file", "", "comma separated CSV or OFX `paths`")
+	jsonPath := fs.String("json", "", "also write the report as JSON to `path`")
+
+	if ok, err := parseFlags(fs, args); !ok {
+		return err
+	}
+
+	if *files == "" {
+		*files = ask("Enter statement files (CSV or OFX, comma separated)")
+	}
+
+	transactions := []statement.Transaction{}
+	for _, path := range splitList(*files) {
+		parsed, err := statement.ParseFile(path)
+		if err != nil {
+			return err
+		}
+
+		transactions = append(transactions, parsed...)
+	}
+
+	records, err := store.Query(inventory.Query{
+		CardReferenceID: card.CardReferenceID,
+		IncludeDeleted:  true,
+	})
+	if err != nil {
+		return fmt.Errorf("query inventory: %w", err)
+	}
+
+	report := statement.Join(records, transactions)
+
+	alerts := 0
+	for _, spend := range report.Cards {
+		fmt.Printf("%s (%s) on %q: %.2f over %d transactions\n", spend.Record.TokenName, spend.Record.TokenLastFour, spend.Record.MerchantName, spend.Total, len(spend.Transactions))
+		for _, alert := range spend.Alerts {
+			fmt.Printf("  ! %s %s %.2f, expected %s\n", alert.Transaction.Date.Format(time.DateOnly), alert.Transaction.Description, alert.Transaction.Amount, alert.Expected)
+		}
+		alerts += len(spend.Alerts)
+	}
+
+	for _, transaction := range report.Unmatched {
+		fmt.Printf("? %s %s (%s) %.2f\n", transaction.Date.Format(time.DateOnly), transaction.Description, transaction.LastFour, transaction.Amount)
+	}
+
+	if *jsonPath != "" {
+		contents, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		if err := os.WriteFile(*jsonPath, contents, 0600); err != nil {
+			return err
+		}
+	}
+
+	log.Info("Imported transactions", "count", len(transactions), "cards", len(report.Cards), "unmatched", len(report.Unmatched), "alerts", alerts, "total", fmt.Sprintf("%.2f", report.Total))
+	if len(report.Unmatched) > 0 {
+		log.Info("Run sync to refresh the inventory if cards are missing")
+	}
+
+	return nil
+}
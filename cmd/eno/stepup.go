@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/saucesteals/eno"
+	"github.com/saucesteals/eno/extension"
+	"github.com/saucesteals/eno/web"
+)
+
+func stepUp(ctx context.Context, client *eno.Client, card extension.PaymentCard) error {
+	result, err := client.StepUp(ctx, card)
+	if err != nil {
+		return fmt.Errorf("step-up (profile %s, %d attempts remaining): %w", result.ProfileStatus, result.RemainingAttempts, err)
+	}
+
+	if result.Reused {
+		log.Info("Reusing saved step-up")
+		return nil
+	}
+
+	if !result.Required {
+		log.Info("Step-up not required")
+		return nil
+	}
+
+	log.Info("Completed step-up", "contactPoint", result.Destination, "attempts", result.Attempts, "expires", result.ExpiresAt.Format(time.DateTime))
+	return nil
+}
+
+func stepUpStatus(ctx context.Context, capWeb *web.Web, card extension.PaymentCard) error {
+	status, err := capWeb.StepUpStatus(ctx, card)
+	if err != nil {
+		return fmt.Errorf("step-up status: %w", err)
+	}
+
+	switch {
+	case status.Authorization != nil:
+		log.Info("Step-up not required, reusing saved step-up", "expires", status.Authorization.ExpiresAt.Format(time.DateTime))
+	case !status.Required:
+		log.Info("Step-up not required")
+	default:
+		log.Info("Step-up required")
+		for _, option := range status.Options {
+			if option.Supported() {
+				fmt.Printf("- %s\n", option)
+			} else {
+				fmt.Printf("- %s (unsupported)\n", option)
+			}
+		}
+	}
+
+	return nil
+}
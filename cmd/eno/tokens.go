@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/saucesteals/eno/extension"
+	"github.com/saucesteals/eno/web"
+)
+
+func fetchTokens(ctx context.Context, capWeb *web.Web, card extension.PaymentCard, query web.TokenQuery) ([]web.ListedToken, error) {
+	tokens := []web.ListedToken{}
+	for token, err := range capWeb.Tokens(ctx, card, query) {
+		if err != nil {
+			return nil, fmt.Errorf("list tokens: %w", err)
+		}
+
+		tokens = append(tokens, token)
+	}
+
+	return tokens, nil
+}
+
+// selectTokens asks for a name filter and which of the matching tokens to use.
+func selectTokens(ctx context.Context, capWeb *web.Web, card extension.PaymentCard) ([]web.ListedToken, error) {
+	nameFilter := ask("Enter name filter (optional)")
+
+	tokens, err := fetchTokens(ctx, capWeb, card, web.NewTokenQuery().Name(nameFilter))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	for i, token := range tokens {
+		fmt.Printf("%d. %s (%s)\n", i+1, token.TokenName, token.TokenLastFour)
+	}
+
+	selection := ask("Select cards (e.g. 1,3 or all)")
+	if selection == "all" {
+		return tokens, nil
+	}
+
+	selected := []web.ListedToken{}
+	for _, part := range strings.Split(selection, ",") {
+		index, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("invalid selection: %s", part)
+		}
+
+		index--
+		if index < 0 || index >= len(tokens) {
+			return nil, fmt.Errorf("unknown card: %d", index+1)
+		}
+
+		selected = append(selected, tokens[index])
+	}
+
+	return selected, nil
+}
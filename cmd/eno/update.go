@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/saucesteals/eno/extension"
+	"github.com/saucesteals/eno/web"
+)
+
+type TokenSnapshot struct {
+	CreatedAt       time.Time        `json:"createdAt"`
+	Action          string           `json:"action"`
+	CardReferenceID string           `json:"cardReferenceId"`
+	Tokens          []web.TokenState `json:"tokens"`
+}
+
+func saveSnapshot(profile *Profile, action string, card extension.PaymentCard, tokens []web.ListedToken) (string, error) {
+	dir, err := profile.GetDirectory("snapshots")
+	if err != nil {
+		return "", err
+	}
+
+	snapshot := TokenSnapshot{
+		CreatedAt:       time.Now(),
+		Action:          action,
+		CardReferenceID: card.CardReferenceID,
+		Tokens:          []web.TokenState{},
+	}
+
+	for _, token := range tokens {
+		snapshot.Tokens = append(snapshot.Tokens, web.NewTokenState(token))
+	}
+
+	contents, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("%s_%s.json", snapshot.CreatedAt.Format("2006_01_02_15_04_05"), action))
+	if err := os.WriteFile(path, contents, 0600); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// update applies fn to a selection of tokens after snapshotting their state.
+func update(ctx context.Context, profile *Profile, capWeb *web.Web, card extension.PaymentCard, action string, fn func(i int, token web.ListedToken) error) error {
+	tokens, err := selectTokens(ctx, capWeb, card)
+	if err != nil {
+		return err
+	}
+
+	log.Info("Selected cards", "count", len(tokens))
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	confirm := ask(fmt.Sprintf("Are you sure you want to %s these cards? (y/n)", action))
+	if confirm != "y" {
+		return nil
+	}
+
+	path, err := saveSnapshot(profile, action, card, tokens)
+	if err != nil {
+		return fmt.Errorf("save snapshot: %w", err)
+	}
+	log.Info("Saved snapshot", "path", path)
+
+	failed := 0
+	for i, token := range tokens {
+		if err := fn(i, token); err != nil {
+			failed++
+			log.Error(fmt.Sprintf("(%d/%d) Failed to %s card", i+1, len(tokens), action), "card", token.TokenName, "error", err)
+			continue
+		}
+
+		log.Info(fmt.Sprintf("(%d/%d) Updated card", i+1, len(tokens)), "card", token.TokenName)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to %s %d cards", action, failed)
+	}
+
+	return nil
+}
+
+func lock(ctx context.Context, profile *Profile, capWeb *web.Web, card extension.PaymentCard) error {
+	return update(ctx, profile, capWeb, card, "lock", func(_ int, token web.ListedToken) error {
+		return capWeb.SetTokenAuthorizations(ctx, card, token, false)
+	})
+}
+
+func unlock(ctx context.Context, profile *Profile, capWeb *web.Web, card extension.PaymentCard) error {
+	return update(ctx, profile, capWeb, card, "unlock", func(_ int, token web.ListedToken) error {
+		return capWeb.SetTokenAuthorizations(ctx, card, token, true)
+	})
+}
+
+func rename(ctx context.Context, profile *Profile, capWeb *web.Web, card extension.PaymentCard) error {
+	name := ask("Enter new name ({n} is replaced with the card number)")
+	if name == "" {
+		return fmt.Errorf("name is required")
+	}
+
+	return update(ctx, profile, capWeb, card, "rename", func(i int, token web.ListedToken) error {
+		return capWeb.RenameToken(ctx, card, token, strings.ReplaceAll(name, "{n}", strconv.Itoa(i+1)))
+	})
+}
+
+func bind(ctx context.Context, profile *Profile, capWeb *web.Web, capExt *extension.Extension, card extension.PaymentCard) error {
+	merchantUrl := ask("Enter merchant URL (e.g. www.google.com)")
+
+	merchant, err := capExt.DataSourceSearch(ctx, merchantUrl)
+	if err != nil {
+		return fmt.Errorf("failed to search for merchant: %w", err)
+	}
+
+	log.Info("Found merchant", "name", merchant.Name, "url", merchant.MerchantUrl)
+
+	return update(ctx, profile, capWeb, card, "bind", func(_ int, token web.ListedToken) error {
+		return capWeb.BindToken(ctx, card, token, merchant)
+	})
+}
+
+func unbind(ctx context.Context, profile *Profile, capWeb *web.Web, card extension.PaymentCard) error {
+	return update(ctx, profile, capWeb, card, "unbind", func(_ int, token web.ListedToken) error {
+		return capWeb.UnbindToken(ctx, card, token)
+	})
+}
+
+func revert(ctx context.Context, profile *Profile, capWeb *web.Web, card extension.PaymentCard) error {
+	dir, err := profile.GetDirectory("snapshots")
+	if err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	paths := []string{}
+	snapshots := []TokenSnapshot{}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		var snapshot TokenSnapshot
+		if err := json.Unmarshal(contents, &snapshot); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		if snapshot.CardReferenceID != card.CardReferenceID {
+			continue
+		}
+
+		paths = append(paths, path)
+		snapshots = append(snapshots, snapshot)
+	}
+
+	if len(snapshots) == 0 {
+		log.Info("No snapshots found")
+		return nil
+	}
+
+	slices.Reverse(paths)
+	slices.Reverse(snapshots)
+
+	fmt.Printf("Snapshots:\n")
+	for i, snapshot := range snapshots {
+		fmt.Printf("%d. %s before %s of %d cards\n", i+1, snapshot.CreatedAt.Format(time.DateTime), snapshot.Action, len(snapshot.Tokens))
+	}
+
+	index, err := strconv.Atoi(ask("Select a snapshot"))
+	if err != nil {
+		return fmt.Errorf("invalid snapshot")
+	}
+
+	index--
+	if index < 0 || index >= len(snapshots) {
+		return fmt.Errorf("unknown snapshot")
+	}
+
+	snapshot := snapshots[index]
+	confirm := ask(fmt.Sprintf("Are you sure you want to revert %d cards? (y/n)", len(snapshot.Tokens)))
+	if confirm != "y" {
+		return nil
+	}
+
+	failed := 0
+	for i, state := range snapshot.Tokens {
+		if err := capWeb.RestoreToken(ctx, card, state); err != nil {
+			failed++
+			log.Error(fmt.Sprintf("(%d/%d) Failed to revert card", i+1, len(snapshot.Tokens)), "card", state.TokenName, "error", err)
+			continue
+		}
+
+		log.Info(fmt.Sprintf("(%d/%d) Reverted card", i+1, len(snapshot.Tokens)), "card", state.TokenName)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to revert %d cards", failed)
+	}
+
+	log.Info("Reverted snapshot", "path", paths[index])
+	return nil
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+
+	"github.com/saucesteals/eno/otp"
 )
 
 type OPTSmsContactDetails struct {
@@ -88,11 +90,27 @@ func (a *Extension) OTPSend(ctx context.Context, contactPoint string) (OTPAuthen
 }
 
 type OTPResult struct {
-	ProfileStatus                 string `json:"profileStatus"`
-	AcceptanceStatus              string `json:"acceptanceStatus"`
-	ValidationStatus              string `json:"validationStatus"`
-	PinAuthenticationFailureCount int    `json:"pinAuthenticationFailureCount"`
-	UpgradedForgeRockCookie       string `json:"upgradedForgeRockCookie"`
+	ProfileStatus                 otp.ProfileStatus `json:"profileStatus"`
+	AcceptanceStatus              string            `json:"acceptanceStatus"`
+	ValidationStatus              string            `json:"validationStatus"`
+	PinAuthenticationFailureCount int               `json:"pinAuthenticationFailureCount"`
+	UpgradedForgeRockCookie       string            `json:"upgradedForgeRockCookie"`
+}
+
+// Result describes the profile state given that maxFailures wrong codes lock
+// it. The response only counts failures, so the remaining attempts are
+// unknown when maxFailures is zero.
+func (r OTPResult) Result(maxFailures int) otp.Result {
+	remaining := -1
+	if maxFailures > 0 {
+		remaining = max(maxFailures-r.PinAuthenticationFailureCount, 0)
+	}
+
+	return otp.Result{
+		Accepted:          r.AcceptanceStatus == "ACCEPTED",
+		ProfileStatus:     r.ProfileStatus,
+		RemainingAttempts: remaining,
+	}
 }
 
 func (a *Extension) OTPValidate(ctx context.Context, pin string, pinAuthenticationToken string) (OTPResult, error) {
@@ -125,10 +143,10 @@ func (a *Extension) OTPValidate(ctx context.Context, pin string, pinAuthenticati
 	}
 
 	if response.AcceptanceStatus != "ACCEPTED" {
-		return response, fmt.Errorf("acceptance status: %s", response.AcceptanceStatus)
+		return response, fmt.Errorf("%w: acceptance status: %s", otp.ErrRejected, response.AcceptanceStatus)
 	}
 
-	if response.ProfileStatus != "UNLOCKED" {
+	if response.ProfileStatus != otp.ProfileStatusUnlocked {
 		return response, fmt.Errorf("profile status: %s", response.ProfileStatus)
 	}
 
@@ -142,3 +160,47 @@ func (a *Extension) OTPValidate(ctx context.Context, pin string, pinAuthenticati
 
 	return response, nil
 }
+
+type OTPChallengeOptions struct {
+	Provider otp.Provider
+	Select   otp.Selector
+	// MinRemainingAttempts stops retrying wrong codes once this many attempts
+	// remain.
+	MinRemainingAttempts int
+	// MaxFailures is how many wrong codes lock the profile. The server does
+	// not say, so wrong codes are not retried when zero.
+	MaxFailures int
+}
+
+// OTPChallenge completes the login challenge, retrying wrong codes and
+// re-sending codes as allowed by opts.
+func (a *Extension) OTPChallenge(ctx context.Context, opts OTPChallengeOptions) (otp.Result, error) {
+	options, err := a.OTPGenerate(ctx)
+	if err != nil {
+		return otp.Result{RemainingAttempts: -1}, fmt.Errorf("otp generate: %w", err)
+	}
+
+	destinations := []otp.Destination{}
+	for _, contactPoints := range [][]OPTSmsContactDetails{options.SmsContactDetails, options.HomeContactDetails, options.WorkContactDetails} {
+		for _, contactPoint := range contactPoints {
+			destinations = append(destinations, otp.Destination{
+				Label:        contactPoint.ContactPoint,
+				ContactPoint: contactPoint.ContactPoint,
+				Medium:       "SMS",
+			})
+		}
+	}
+
+	return otp.Run(ctx, opts.Provider, otp.Challenge[OTPAuthentication]{
+		Destinations: destinations,
+		Select:       opts.Select,
+		Send: func(ctx context.Context, destination otp.Destination) (OTPAuthentication, error) {
+			return a.OTPSend(ctx, destination.ContactPoint)
+		},
+		Validate: func(ctx context.Context, sent OTPAuthentication, code string) (otp.Result, error) {
+			response, err := a.OTPValidate(ctx, code, sent.PinAuthenticationToken)
+			return response.Result(opts.MaxFailures), err
+		},
+		MinRemainingAttempts: opts.MinRemainingAttempts,
+	})
+}
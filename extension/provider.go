@@ -0,0 +1,61 @@
+package extension
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/saucesteals/eno/api"
+)
+
+var _ api.TokenProvider = (*Extension)(nil)
+
+func (a *Extension) Mode() api.TokenMode {
+	return api.TokenModeExtension
+}
+
+// Capabilities of extension tokens: they are always bound to a merchant,
+// which can be looked up by URL.
+func (a *Extension) Capabilities() api.Capabilities {
+	return api.Capabilities{
+		MerchantBinding:  true,
+		MerchantLookup:   true,
+		RequiresMerchant: true,
+	}
+}
+
+func (a *Extension) Create(ctx context.Context, req api.TokenRequest) (api.TokenResult, error) {
+	if err := a.Capabilities().Check(req); err != nil {
+		return api.TokenResult{}, err
+	}
+
+	merchant := DataSource{
+		MDXId:       req.Merchant.MdxID,
+		MDXUrlId:    req.Merchant.MdxURLID,
+		Name:        req.Merchant.Name,
+		MerchantUrl: req.Merchant.URL,
+	}
+
+	if merchant.MDXId == "" {
+		var err error
+		merchant, err = a.DataSourceSearch(ctx, req.Merchant.URL)
+		if err != nil {
+			return api.TokenResult{}, fmt.Errorf("%w: merchant lookup: %w", api.ErrNotCreated, err)
+		}
+	}
+
+	token, err := a.CreateToken(ctx, req.Name, PaymentCard{CardReferenceID: req.CardReferenceID}, merchant)
+	if err != nil {
+		return api.TokenResult{}, err
+	}
+
+	return api.TokenResult{
+		Token: token,
+		Mode:  a.Mode(),
+		Merchant: &api.Merchant{
+			MdxID:    merchant.MDXId,
+			MdxURLID: merchant.MDXUrlId,
+			Name:     merchant.Name,
+			URL:      merchant.MerchantUrl,
+		},
+	}, nil
+}
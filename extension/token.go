@@ -2,6 +2,7 @@ package extension
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 
 	"github.com/saucesteals/eno/api"
@@ -44,6 +45,10 @@ func (a *Extension) CreateToken(ctx context.Context, tokenName string, card Paym
 		return response, err
 	}
 
+	// The response is annotated once its token is decrypted
+	a.api.HAR.Hold(req)
+	defer a.api.HAR.Done(req)
+
 	if err := a.do(req, &response); err != nil {
 		return response, err
 	}
@@ -54,5 +59,12 @@ func (a *Extension) CreateToken(ctx context.Context, tokenName string, card Paym
 	}
 
 	response.Token = token
+
+	if a.api.HAR != nil {
+		if cleartext, err := json.Marshal(response); err == nil {
+			a.api.HAR.AnnotateResponse(req, cleartext)
+		}
+	}
+
 	return response, nil
 }
@@ -5,6 +5,7 @@ go 1.24
 toolchain go1.24.4
 
 require (
+	github.com/BurntSushi/toml v1.6.0
 	github.com/go-jose/go-jose/v3 v3.0.4
 	github.com/go-rod/rod v0.116.2
 	github.com/google/uuid v1.6.0
@@ -13,6 +14,8 @@ require (
 	github.com/mileusna/useragent v1.3.5
 	github.com/saucesteals/fhttp v1.0.1
 	github.com/saucesteals/mimic v1.0.1
+	go.etcd.io/bbolt v1.4.3
+	golang.org/x/sys v0.31.0
 )
 
 require (
@@ -28,6 +31,5 @@ require (
 	github.com/ysmood/leakless v0.9.0 // indirect
 	golang.org/x/crypto v0.36.0 // indirect
 	golang.org/x/net v0.38.0 // indirect
-	golang.org/x/sys v0.31.0 // indirect
 	golang.org/x/text v0.23.0 // indirect
 )
@@ -0,0 +1,258 @@
+package inventory
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/saucesteals/eno/api"
+	"github.com/saucesteals/eno/extension"
+	"github.com/saucesteals/eno/web"
+)
+
+var (
+	ErrNotFound = errors.New("token not found")
+
+	tokensBucket    = []byte("tokens")
+	syncBucket      = []byte("sync")
+	merchantsBucket = []byte("merchants")
+)
+
+// Record is everything known locally about a virtual card: the server's view
+// from the last sync and where the card came from.
+type Record struct {
+	TokenReferenceID string    `json:"tokenReferenceId"`
+	CardReferenceID  string    `json:"cardReferenceId"`
+	TokenName        string    `json:"tokenName"`
+	TokenLastFour    string    `json:"tokenLastFour"`
+	TokenStatus      string    `json:"tokenStatus"`
+	DerivedStatus    string    `json:"derivedStatus"`
+	TokenType        string    `json:"tokenType"`
+	ExpirationDate   string    `json:"expirationDate"`
+	HasExpired       bool      `json:"hasExpired"`
+	MdxID            string    `json:"mdxId"`
+	MdxURLID         string    `json:"mdxUrlId"`
+	MerchantName     string    `json:"merchantName"`
+	MerchantURL      string    `json:"merchantUrl"`
+	CreatedAt        time.Time `json:"createdAt"`
+	UpdatedAt        time.Time `json:"updatedAt"`
+
+	// Deleted is set once the card is deleted or no longer listed by the server
+	Deleted  bool      `json:"deleted"`
+	SyncedAt time.Time `json:"syncedAt"`
+
+	// Batch, ExportPath and Mode are recorded when the card is created by eno
+	Batch      string `json:"batch,omitempty"`
+	ExportPath string `json:"exportPath,omitempty"`
+	Mode       string `json:"mode,omitempty"`
+}
+
+func (r Record) Age() time.Duration {
+	return time.Since(r.CreatedAt)
+}
+
+// update overwrites the server's view of the card, keeping local provenance.
+func (r *Record) update(token web.ListedToken) {
+	r.TokenReferenceID = token.TokenReferenceID
+	r.CardReferenceID = token.CardReferenceID
+	r.TokenName = token.TokenName
+	r.TokenLastFour = token.TokenLastFour
+	r.TokenStatus = token.TokenStatus
+	r.DerivedStatus = token.DerivedStatus
+	r.TokenType = token.TokenType
+	r.ExpirationDate = token.FormattedTokenExpirationDate
+	r.HasExpired = token.Expired()
+	r.MdxID = token.MdxInfo.MdxID
+	r.MdxURLID = token.MdxInfo.MdxURLID
+	r.MerchantName = token.MdxInfo.Name
+	r.MerchantURL = token.MdxInfo.MerchantURL
+	r.Deleted = false
+
+	if createdAt, err := token.CreatedAt(); err == nil {
+		r.CreatedAt = createdAt
+	}
+
+	if updatedAt, err := token.UpdatedAt(); err == nil {
+		r.UpdatedAt = updatedAt
+	}
+}
+
+// NewCreatedRecord describes a card eno just created for card. batch and
+// exportPath identify the run and file it was written to.
+func NewCreatedRecord(card extension.PaymentCard, token api.Token, mode string, batch string, exportPath string) Record {
+	now := time.Now()
+
+	record := Record{
+		TokenReferenceID: token.TokenReferenceID,
+		CardReferenceID:  card.CardReferenceID,
+		TokenName:        token.TokenName,
+		TokenLastFour:    token.LastFour,
+		TokenStatus:      token.TokenStatus,
+		TokenType:        token.TokenType,
+		ExpirationDate:   token.ExpirationDate,
+		MdxID:            token.TokenRules.MerchantBinding.MdxID,
+		MdxURLID:         token.TokenRules.MerchantBinding.URLID,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+		Batch:            batch,
+		ExportPath:       exportPath,
+		Mode:             mode,
+	}
+
+	if name, ok := token.TokenRules.MerchantBinding.MerchantName.(string); ok {
+		record.MerchantName = name
+	}
+
+	if record.TokenLastFour == "" && len(token.Token) >= 4 {
+		record.TokenLastFour = token.Token[len(token.Token)-4:]
+	}
+
+	return record
+}
+
+type syncState struct {
+	LastUpdated  time.Time `json:"lastUpdated"`
+	LastSync     time.Time `json:"lastSync"`
+	LastFullSync time.Time `json:"lastFullSync"`
+}
+
+// Store is a local database of virtual cards kept in a single bbolt file.
+type Store struct {
+	db *bolt.DB
+
+	muMerchant sync.Mutex
+}
+
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second * 5})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{tokensBucket, syncBucket, merchantsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func (s *Store) Get(tokenReferenceID string) (Record, error) {
+	var record Record
+	err := s.db.View(func(tx *bolt.Tx) error {
+		contents := tx.Bucket(tokensBucket).Get([]byte(tokenReferenceID))
+		if contents == nil {
+			return ErrNotFound
+		}
+
+		return json.Unmarshal(contents, &record)
+	})
+
+	return record, err
+}
+
+// Put stores records, merging their provenance with what is already known.
+func (s *Store) Put(records ...Record) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(tokensBucket)
+		for _, record := range records {
+			if existing, err := getRecord(bucket, record.TokenReferenceID); err == nil {
+				record.Batch = cmp.Or(record.Batch, existing.Batch)
+				record.ExportPath = cmp.Or(record.ExportPath, existing.ExportPath)
+				record.Mode = cmp.Or(record.Mode, existing.Mode)
+			}
+
+			if err := putRecord(bucket, record); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// MarkDeleted flags the given tokens as deleted without removing their
+// history.
+func (s *Store) MarkDeleted(tokenReferenceIDs ...string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(tokensBucket)
+		for _, id := range tokenReferenceIDs {
+			record, err := getRecord(bucket, id)
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			record.Deleted = true
+			if err := putRecord(bucket, record); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Query returns every record matching q.
+func (s *Store) Query(q Query) ([]Record, error) {
+	records := []Record{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(tokensBucket).ForEach(func(_, contents []byte) error {
+			var record Record
+			if err := json.Unmarshal(contents, &record); err != nil {
+				return err
+			}
+
+			if q.Matches(record) {
+				records = append(records, record)
+			}
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	q.sort(records)
+	return records, nil
+}
+
+func getRecord(bucket *bolt.Bucket, tokenReferenceID string) (Record, error) {
+	contents := bucket.Get([]byte(tokenReferenceID))
+	if contents == nil {
+		return Record{}, ErrNotFound
+	}
+
+	var record Record
+	err := json.Unmarshal(contents, &record)
+	return record, err
+}
+
+func putRecord(bucket *bolt.Bucket, record Record) error {
+	contents, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	return bucket.Put([]byte(record.TokenReferenceID), contents)
+}
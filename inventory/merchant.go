@@ -0,0 +1,166 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/saucesteals/eno/api"
+	"github.com/saucesteals/eno/extension"
+	"github.com/saucesteals/eno/web"
+)
+
+// MerchantCard is the card used for a merchant. Token is only set when the
+// card was just created, as full card details are not listed by the server.
+type MerchantCard struct {
+	Record
+	Merchant api.Merchant
+	Token    *api.Token
+	Created  bool
+}
+
+func merchantKey(card extension.PaymentCard, mdxID string) []byte {
+	return []byte(card.CardReferenceID + "/" + mdxID)
+}
+
+// merchantRecord returns the token reference ID of the card last used for a
+// merchant.
+func (s *Store) merchantRecord(card extension.PaymentCard, mdxID string) (string, error) {
+	var tokenReferenceID string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(merchantsBucket).Get(merchantKey(card, mdxID))
+		if value == nil {
+			return ErrNotFound
+		}
+
+		tokenReferenceID = string(value)
+		return nil
+	})
+
+	return tokenReferenceID, err
+}
+
+func (s *Store) putMerchantRecord(card extension.PaymentCard, mdxID string, record Record) error {
+	if err := s.Put(record); err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(merchantsBucket).Put(merchantKey(card, mdxID), []byte(record.TokenReferenceID))
+	})
+}
+
+// GetOrCreateForMerchant returns the active, unpaused card bound to merchant
+// among the live tokens, creating one named tokenName with provider if there
+// is none. tokens lists the live tokens matching a query.
+//
+// The card last used for the merchant is looked up first by its name and last
+// four digits, so repeated calls only fetch a single page. Every active token
+// is only listed if that card was renamed, paused or deleted elsewhere, which
+// does not update the inventory.
+func (s *Store) GetOrCreateForMerchant(ctx context.Context, tokens func(web.TokenQuery) iter.Seq2[web.ListedToken, error], provider api.TokenProvider, card extension.PaymentCard, merchant api.Merchant, tokenName string) (MerchantCard, error) {
+	s.muMerchant.Lock()
+	defer s.muMerchant.Unlock()
+
+	if merchant.MdxID == "" {
+		return MerchantCard{}, fmt.Errorf("no merchant found for %s", merchant.URL)
+	}
+
+	last, err := s.merchantRecord(card, merchant.MdxID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return MerchantCard{}, err
+	}
+
+	usable := func(token web.ListedToken) bool {
+		return token.MdxInfo.MdxID == merchant.MdxID && !token.Expired() &&
+			web.TokenStatus(token.TokenStatus) == web.TokenStatusActive && token.AllowsAuthorizations()
+	}
+
+	var found *web.ListedToken
+	if last != "" {
+		remembered, err := s.Get(last)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return MerchantCard{}, err
+		}
+
+		if err == nil {
+			query := web.NewTokenQuery().Name(remembered.TokenName).Status(web.TokenStatusActive).LastFour(remembered.TokenLastFour)
+			found, err = findToken(tokens(query), func(token web.ListedToken) bool {
+				return token.TokenReferenceID == last && usable(token)
+			})
+			if err != nil {
+				return MerchantCard{}, err
+			}
+		}
+	}
+
+	if found == nil {
+		found, err = findToken(tokens(web.NewTokenQuery().Status(web.TokenStatusActive)), usable)
+		if err != nil {
+			return MerchantCard{}, err
+		}
+	}
+
+	if found != nil {
+		record, err := s.Get(found.TokenReferenceID)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return MerchantCard{}, err
+		}
+		record.update(*found)
+
+		if err := s.putMerchantRecord(card, merchant.MdxID, record); err != nil {
+			return MerchantCard{}, err
+		}
+
+		return MerchantCard{Record: record, Merchant: merchant}, nil
+	}
+
+	if tokenName == "" {
+		tokenName = merchant.Name + " Card"
+	}
+
+	result, err := provider.Create(ctx, api.TokenRequest{
+		CardReferenceID: card.CardReferenceID,
+		Name:            tokenName,
+		Merchant:        &merchant,
+	})
+	if err != nil {
+		return MerchantCard{}, fmt.Errorf("create token: %w", err)
+	}
+
+	token := result.Token
+	record := NewCreatedRecord(card, token, string(result.Mode), "", "")
+	record.MdxID = merchant.MdxID
+	record.MdxURLID = merchant.MdxURLID
+	record.MerchantName = merchant.Name
+	record.MerchantURL = merchant.URL
+
+	created := MerchantCard{Record: record, Merchant: merchant, Token: &token, Created: true}
+	if token.TokenReferenceID == "" {
+		return created, nil
+	}
+
+	if err := s.putMerchantRecord(card, merchant.MdxID, record); err != nil {
+		return created, err
+	}
+
+	return created, nil
+}
+
+// findToken returns the first token matching match, or nil if there is none.
+func findToken(tokens iter.Seq2[web.ListedToken, error], match func(web.ListedToken) bool) (*web.ListedToken, error) {
+	for token, err := range tokens {
+		if err != nil {
+			return nil, fmt.Errorf("list tokens: %w", err)
+		}
+
+		if match(token) {
+			return &token, nil
+		}
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,101 @@
+package inventory
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+type SortField string
+
+var (
+	SortByCreated SortField = "created"
+	SortByUpdated SortField = "updated"
+	SortByName    SortField = "name"
+)
+
+// Query filters records offline. Empty fields match everything.
+type Query struct {
+	CardReferenceID string
+	// Name and Merchant match case-insensitive substrings
+	Name     string
+	Merchant string
+	// Statuses match either the token or the derived status
+	Statuses []string
+	LastFour []string
+	Batch    string
+	MinAge   time.Duration
+	MaxAge   time.Duration
+
+	IncludeDeleted bool
+
+	SortBy     SortField
+	Descending bool
+}
+
+func (q Query) Matches(record Record) bool {
+	if record.Deleted && !q.IncludeDeleted {
+		return false
+	}
+
+	if q.CardReferenceID != "" && record.CardReferenceID != q.CardReferenceID {
+		return false
+	}
+
+	if q.Name != "" && !containsFold(record.TokenName, q.Name) {
+		return false
+	}
+
+	if q.Merchant != "" && !containsFold(record.MerchantName, q.Merchant) && !containsFold(record.MerchantURL, q.Merchant) {
+		return false
+	}
+
+	if len(q.Statuses) > 0 && !slices.ContainsFunc(q.Statuses, func(status string) bool {
+		return strings.EqualFold(status, record.TokenStatus) || strings.EqualFold(status, record.DerivedStatus)
+	}) {
+		return false
+	}
+
+	if len(q.LastFour) > 0 && !slices.Contains(q.LastFour, record.TokenLastFour) {
+		return false
+	}
+
+	if q.Batch != "" && record.Batch != q.Batch {
+		return false
+	}
+
+	age := record.Age()
+	if q.MinAge > 0 && age < q.MinAge {
+		return false
+	}
+
+	if q.MaxAge > 0 && age > q.MaxAge {
+		return false
+	}
+
+	return true
+}
+
+func (q Query) sort(records []Record) {
+	slices.SortStableFunc(records, func(a, b Record) int {
+		var c int
+		switch q.SortBy {
+		case SortByName:
+			c = strings.Compare(a.TokenName, b.TokenName)
+		case SortByUpdated:
+			c = a.UpdatedAt.Compare(b.UpdatedAt)
+		default:
+			c = a.CreatedAt.Compare(b.CreatedAt)
+		}
+
+		if q.Descending {
+			return -c
+		}
+
+		return c
+	})
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
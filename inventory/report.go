@@ -0,0 +1,131 @@
+package inventory
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	"github.com/saucesteals/eno/web"
+)
+
+type ageBucket struct {
+	label string
+	max   time.Duration
+}
+
+var ageBuckets = []ageBucket{
+	{label: "< 7 days", max: time.Hour * 24 * 7},
+	{label: "7-30 days", max: time.Hour * 24 * 30},
+	{label: "30-90 days", max: time.Hour * 24 * 90},
+	{label: "90-365 days", max: time.Hour * 24 * 365},
+	{label: "> 1 year"},
+}
+
+func ageLabel(age time.Duration) string {
+	for _, bucket := range ageBuckets {
+		if bucket.max == 0 || age < bucket.max {
+			return bucket.label
+		}
+	}
+
+	return ""
+}
+
+type Count struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+// Breakdown counts records by a single attribute.
+type Breakdown struct {
+	Name   string  `json:"name"`
+	Counts []Count `json:"counts"`
+}
+
+type Report struct {
+	GeneratedAt time.Time `json:"generatedAt"`
+	// Total counts the cards that were not deleted, which are the only ones
+	// in the breakdowns
+	Total      int         `json:"total"`
+	Deleted    int         `json:"deleted"`
+	Breakdowns []Breakdown `json:"breakdowns"`
+	// Expiring are cards that expire within the requested window, soonest
+	// first
+	Expiring []Record `json:"expiring"`
+}
+
+// NewReport summarizes records by merchant, status, type, creation month,
+// age and batch, and lists the cards expiring before now+expiringWithin.
+// Deleted records are only counted.
+func NewReport(records []Record, now time.Time, expiringWithin time.Duration) Report {
+	deleted := len(records)
+	records = slices.DeleteFunc(slices.Clone(records), func(r Record) bool { return r.Deleted })
+	deleted -= len(records)
+
+	report := Report{
+		GeneratedAt: now,
+		Total:       len(records),
+		Deleted:     deleted,
+		Expiring:    []Record{},
+	}
+
+	breakdown := func(name string, key func(Record) string, ordered bool) Breakdown {
+		counts := map[string]int{}
+		for _, record := range records {
+			counts[key(record)]++
+		}
+
+		b := Breakdown{Name: name, Counts: []Count{}}
+		for key, count := range counts {
+			b.Counts = append(b.Counts, Count{Key: key, Count: count})
+		}
+
+		slices.SortFunc(b.Counts, func(x, y Count) int {
+			if ordered {
+				return cmp.Compare(x.Key, y.Key)
+			}
+			return cmp.Or(cmp.Compare(y.Count, x.Count), cmp.Compare(x.Key, y.Key))
+		})
+
+		return b
+	}
+
+	orDefault := func(value string) string {
+		return cmp.Or(value, "(none)")
+	}
+
+	ages := breakdown("age", func(r Record) string { return ageLabel(now.Sub(r.CreatedAt)) }, false)
+	slices.SortFunc(ages.Counts, func(x, y Count) int {
+		return cmp.Compare(
+			slices.IndexFunc(ageBuckets, func(b ageBucket) bool { return b.label == x.Key }),
+			slices.IndexFunc(ageBuckets, func(b ageBucket) bool { return b.label == y.Key }),
+		)
+	})
+
+	report.Breakdowns = []Breakdown{
+		breakdown("merchant", func(r Record) string { return orDefault(r.MerchantName) }, false),
+		breakdown("status", func(r Record) string { return orDefault(r.DerivedStatus) }, false),
+		breakdown("type", func(r Record) string { return orDefault(r.TokenType) }, false),
+		breakdown("month", func(r Record) string { return r.CreatedAt.Local().Format("2006-01") }, true),
+		ages,
+		breakdown("batch", func(r Record) string { return orDefault(r.Batch) }, true),
+	}
+
+	deadline := now.Add(expiringWithin)
+	for _, record := range records {
+		expiresAt, err := web.ParseExpirationDate(record.ExpirationDate)
+		if err != nil || record.HasExpired || expiresAt.Before(now) || !expiresAt.Before(deadline) {
+			continue
+		}
+
+		report.Expiring = append(report.Expiring, record)
+	}
+
+	slices.SortFunc(report.Expiring, func(a, b Record) int {
+		x, _ := web.ParseExpirationDate(a.ExpirationDate)
+		y, _ := web.ParseExpirationDate(b.ExpirationDate)
+		return x.Compare(y)
+	})
+
+	return report
+}
@@ -0,0 +1,162 @@
+package inventory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/saucesteals/eno/extension"
+	"github.com/saucesteals/eno/web"
+)
+
+var (
+	// syncOverlap re-reads tokens updated shortly before the last sync to
+	// tolerate clock skew and updates that land mid-sync
+	syncOverlap = time.Minute * 10
+
+	// FullSyncInterval is how often an incremental sync is upgraded to a full
+	// one. Only full syncs notice cards deleted on the server.
+	FullSyncInterval = time.Hour * 24
+)
+
+type SyncResult struct {
+	Full    bool
+	Updated int
+	Deleted int
+}
+
+// Sync refreshes the records of card from the server. Incremental syncs only
+// rewrite the tokens updated since the last sync's watermark; full syncs
+// rewrite every token and mark the ones that are no longer listed as deleted.
+// Both read the whole listing, as its order is not guaranteed. The first sync
+// of a card, and any sync more than FullSyncInterval after the last full one,
+// is always full.
+func (s *Store) Sync(ctx context.Context, capWeb *web.Web, card extension.PaymentCard, full bool) (SyncResult, error) {
+	state, err := s.syncState(card.CardReferenceID)
+	if err != nil {
+		return SyncResult{}, err
+	}
+
+	if state.LastUpdated.IsZero() || time.Since(state.LastFullSync) > FullSyncInterval {
+		full = true
+	}
+
+	watermark := state.LastUpdated.Add(-syncOverlap)
+
+	tokens := []web.ListedToken{}
+	for token, err := range capWeb.Tokens(ctx, card, web.NewTokenQuery()) {
+		if err != nil {
+			return SyncResult{}, err
+		}
+
+		if !full {
+			if updatedAt, err := token.UpdatedAt(); err == nil && updatedAt.Before(watermark) {
+				continue
+			}
+		}
+
+		tokens = append(tokens, token)
+	}
+
+	result := SyncResult{Full: full, Updated: len(tokens)}
+	now := time.Now()
+
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(tokensBucket)
+		seen := map[string]bool{}
+
+		for _, token := range tokens {
+			seen[token.TokenReferenceID] = true
+
+			record, err := getRecord(bucket, token.TokenReferenceID)
+			if err != nil && !errors.Is(err, ErrNotFound) {
+				return err
+			}
+
+			record.update(token)
+			record.SyncedAt = now
+			if record.UpdatedAt.After(state.LastUpdated) {
+				state.LastUpdated = record.UpdatedAt
+			}
+
+			if err := putRecord(bucket, record); err != nil {
+				return err
+			}
+		}
+
+		if full {
+			// bbolt does not allow modifying a bucket while iterating it, so
+			// the records to mark as deleted are collected first
+			deleted := []Record{}
+			err := bucket.ForEach(func(_, contents []byte) error {
+				var record Record
+				if err := json.Unmarshal(contents, &record); err != nil {
+					return err
+				}
+
+				if record.CardReferenceID != card.CardReferenceID || record.Deleted || seen[record.TokenReferenceID] {
+					return nil
+				}
+
+				deleted = append(deleted, record)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			for _, record := range deleted {
+				record.Deleted = true
+				record.SyncedAt = now
+				if err := putRecord(bucket, record); err != nil {
+					return err
+				}
+			}
+
+			result.Deleted = len(deleted)
+		}
+
+		state.LastSync = now
+		if full {
+			state.LastFullSync = now
+		}
+		contents, err := json.Marshal(state)
+		if err != nil {
+			return err
+		}
+
+		return tx.Bucket(syncBucket).Put([]byte(card.CardReferenceID), contents)
+	})
+
+	return result, err
+}
+
+// LastSync returns when card was last synced, or the zero time if never.
+func (s *Store) LastSync(card extension.PaymentCard) (time.Time, error) {
+	state, err := s.syncState(card.CardReferenceID)
+	return state.LastSync, err
+}
+
+// LastFullSync returns when card was last fully synced, or the zero time if
+// never. Cards deleted on the server since then may still be listed.
+func (s *Store) LastFullSync(card extension.PaymentCard) (time.Time, error) {
+	state, err := s.syncState(card.CardReferenceID)
+	return state.LastFullSync, err
+}
+
+func (s *Store) syncState(cardReferenceID string) (syncState, error) {
+	var state syncState
+	err := s.db.View(func(tx *bolt.Tx) error {
+		contents := tx.Bucket(syncBucket).Get([]byte(cardReferenceID))
+		if contents == nil {
+			return nil
+		}
+
+		return json.Unmarshal(contents, &state)
+	})
+
+	return state, err
+}
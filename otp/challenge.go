@@ -0,0 +1,156 @@
+package otp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrRejected         = errors.New("otp rejected")
+	ErrLocked           = errors.New("profile locked")
+	ErrLockoutThreshold = errors.New("stopped before profile lockout")
+)
+
+type ProfileStatus string
+
+var (
+	ProfileStatusUnlocked ProfileStatus = "UNLOCKED"
+	ProfileStatusLocked   ProfileStatus = "LOCKED"
+)
+
+// Result describes the state of the profile after a challenge.
+type Result struct {
+	Accepted      bool
+	ProfileStatus ProfileStatus
+	// RemainingAttempts is how many more codes can be submitted before the
+	// profile locks, or -1 when the server does not say
+	RemainingAttempts int
+	// Attempts is how many codes were submitted
+	Attempts int
+	// Destination is the contact point the last code was sent to
+	Destination string
+}
+
+func (r Result) Locked() bool {
+	return r.ProfileStatus == ProfileStatusLocked
+}
+
+// Destination is somewhere a code can be sent.
+type Destination struct {
+	Label        string
+	ContactPoint string
+	Medium       string
+}
+
+// Selector picks the destination to send a code to. It is called again
+// whenever a new code is requested, so another destination can be chosen.
+type Selector func(ctx context.Context, destinations []Destination) (int, error)
+
+// Challenge describes how to send and validate codes for a single challenge.
+// Validate returns an error wrapping ErrRejected when a code is wrong.
+type Challenge[T any] struct {
+	Destinations []Destination
+	Select       Selector
+	Send         func(ctx context.Context, destination Destination) (T, error)
+	Validate     func(ctx context.Context, sent T, code string) (Result, error)
+
+	// MinRemainingAttempts stops the challenge once this many attempts remain
+	// so the profile is never locked by a wrong code.
+	MinRemainingAttempts int
+}
+
+// Run drives c until a code is accepted. Wrong codes are re-entered through
+// p, and a new code is sent, to a destination chosen again, whenever p times
+// out or requests a resend, up to the provider's resend policy.
+func Run[T any](ctx context.Context, p Provider, c Challenge[T]) (Result, error) {
+	if len(c.Destinations) == 0 {
+		return Result{RemainingAttempts: -1}, errors.New("no contact points found")
+	}
+
+	policy := p.Policy()
+	result := Result{RemainingAttempts: -1}
+
+	var sent T
+	var req Request
+	resend := true
+	for resends := 0; ; {
+		if resend {
+			index, err := c.Select(ctx, c.Destinations)
+			if err != nil {
+				return result, err
+			}
+
+			if index < 0 || index >= len(c.Destinations) {
+				return result, fmt.Errorf("unknown contact point")
+			}
+
+			destination := c.Destinations[index]
+			sent, err = c.Send(ctx, destination)
+			if err != nil {
+				return result, err
+			}
+
+			result.Destination = destination.ContactPoint
+			req = Request{
+				ContactPoint:      destination.ContactPoint,
+				Medium:            destination.Medium,
+				SentAt:            time.Now(),
+				Attempt:           result.Attempts,
+				RemainingAttempts: result.RemainingAttempts,
+				RejectedCodes:     req.RejectedCodes,
+			}
+			resend = false
+		}
+
+		code, err := Wait(ctx, p, req)
+		if errors.Is(err, ErrTimeout) || errors.Is(err, ErrResend) {
+			if resends >= policy.Resends {
+				return result, fmt.Errorf("%w after %d sends", err, resends+1)
+			}
+
+			resends++
+			resend = true
+			continue
+		}
+		if err != nil {
+			return result, err
+		}
+
+		attempt, err := c.Validate(ctx, sent, code)
+		result.Attempts++
+		result.Accepted = attempt.Accepted
+		result.ProfileStatus = attempt.ProfileStatus
+		result.RemainingAttempts = attempt.RemainingAttempts
+		if err == nil {
+			return result, nil
+		}
+
+		if !errors.Is(err, ErrRejected) {
+			return result, err
+		}
+
+		if result.Locked() {
+			return result, fmt.Errorf("%w: %w", ErrLocked, err)
+		}
+
+		// Without knowing how many attempts remain, another wrong code could
+		// lock the profile
+		if result.RemainingAttempts < 0 {
+			return result, fmt.Errorf("%w: remaining attempts unknown: %w", ErrLockoutThreshold, err)
+		}
+
+		if result.RemainingAttempts <= c.MinRemainingAttempts {
+			return result, fmt.Errorf("%w: %d attempts remaining: %w", ErrLockoutThreshold, result.RemainingAttempts, err)
+		}
+
+		// The code is asked for again as of now, so providers do not hand
+		// back the one that was just rejected
+		req.SentAt = time.Now()
+		req.Attempt = result.Attempts
+		req.RemainingAttempts = result.RemainingAttempts
+		req.Rejected = true
+		req.RejectedCodes = append(req.RejectedCodes, code)
+	}
+}
@@ -0,0 +1,91 @@
+package otp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+var (
+	commandRetryInterval = time.Second * 2
+)
+
+// Command runs an external program and extracts the code from its standard
+// output. The program receives the request through ENO_OTP_* environment
+// variables and is run again until its output contains a code.
+type Command struct {
+	name    string
+	args    []string
+	pattern *regexp.Regexp
+	policy  Policy
+}
+
+func NewCommand(name string, args []string, pattern *regexp.Regexp, policy Policy) *Command {
+	if pattern == nil {
+		pattern = DefaultPattern
+	}
+
+	return &Command{
+		name:    name,
+		args:    args,
+		pattern: pattern,
+		policy:  policy,
+	}
+}
+
+func (c *Command) Policy() Policy {
+	return c.policy
+}
+
+func (c *Command) Code(ctx context.Context, req Request) (string, error) {
+	for {
+		code, err := c.run(ctx, req)
+		if err == nil {
+			return code, nil
+		}
+
+		if !errors.Is(err, ErrNoCode) {
+			return "", err
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(commandRetryInterval):
+		}
+	}
+}
+
+func (c *Command) run(ctx context.Context, req Request) (string, error) {
+	cmd := exec.CommandContext(ctx, c.name, c.args...)
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		"ENO_OTP_CONTACT_POINT="+req.ContactPoint,
+		"ENO_OTP_MEDIUM="+req.Medium,
+		"ENO_OTP_SENT_AT="+req.SentAt.Format(time.RFC3339),
+		"ENO_OTP_ATTEMPT="+strconv.Itoa(req.Attempt),
+		"ENO_OTP_REMAINING_ATTEMPTS="+strconv.Itoa(req.RemainingAttempts),
+		"ENO_OTP_REJECTED="+strconv.FormatBool(req.Rejected),
+	)
+
+	out, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+
+		return "", fmt.Errorf("run %s: %w", c.name, err)
+	}
+
+	code, ok := Extract(c.pattern, string(out))
+	if !ok {
+		return "", fmt.Errorf("run %s: %w", c.name, ErrNoCode)
+	}
+
+	return code, nil
+}
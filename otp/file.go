@@ -0,0 +1,119 @@
+package otp
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"regexp"
+	"slices"
+	"time"
+)
+
+var (
+	filePollInterval = time.Millisecond * 500
+)
+
+// File reads codes from a regular file or a named pipe. Regular files are
+// polled until they are modified after the code was sent, named pipes are read
+// line by line until a line contains a code.
+type File struct {
+	path    string
+	pattern *regexp.Regexp
+	policy  Policy
+}
+
+func NewFile(path string, pattern *regexp.Regexp, policy Policy) *File {
+	if pattern == nil {
+		pattern = DefaultPattern
+	}
+
+	return &File{
+		path:    path,
+		pattern: pattern,
+		policy:  policy,
+	}
+}
+
+func (f *File) Policy() Policy {
+	return f.policy
+}
+
+func (f *File) Code(ctx context.Context, req Request) (string, error) {
+	info, err := os.Stat(f.path)
+	if err == nil && info.Mode()&os.ModeNamedPipe != 0 {
+		return f.readPipe(ctx)
+	}
+
+	return f.pollFile(ctx, req)
+}
+
+func (f *File) pollFile(ctx context.Context, req Request) (string, error) {
+	ticker := time.NewTicker(filePollInterval)
+	defer ticker.Stop()
+
+	for {
+		info, err := os.Stat(f.path)
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+
+		if err == nil && !info.ModTime().Before(req.SentAt.Truncate(time.Second)) {
+			contents, err := os.ReadFile(f.path)
+			if err != nil {
+				return "", err
+			}
+
+			// Files written within the second a code was rejected still
+			// hold it, so it is skipped until the file changes
+			if code, ok := Extract(f.pattern, string(contents)); ok && !slices.Contains(req.RejectedCodes, code) {
+				return code, nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// readPipe leaves its reader blocked on the pipe until a writer opens it if
+// ctx is done first.
+func (f *File) readPipe(ctx context.Context) (string, error) {
+	type result struct {
+		code string
+		err  error
+	}
+
+	results := make(chan result, 1)
+	go func() {
+		pipe, err := os.Open(f.path)
+		if err != nil {
+			results <- result{err: err}
+			return
+		}
+		defer pipe.Close()
+
+		scanner := bufio.NewScanner(pipe)
+		for scanner.Scan() {
+			if code, ok := Extract(f.pattern, scanner.Text()); ok {
+				results <- result{code: code}
+				return
+			}
+		}
+
+		err = scanner.Err()
+		if err == nil {
+			err = ErrNoCode
+		}
+		results <- result{err: err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case res := <-results:
+		return res.code, res.err
+	}
+}
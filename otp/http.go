@@ -0,0 +1,156 @@
+package otp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	httpMessageFields = []string{"message", "body", "text", "content"}
+)
+
+type httpMessage struct {
+	code       string
+	receivedAt time.Time
+}
+
+// HTTPListener accepts SMS messages forwarded to a local HTTP endpoint (e.g. by
+// a phone automation app) and extracts codes from them. Messages may be posted
+// as plain text, as a form or as JSON with a message, body, text or content
+// field.
+type HTTPListener struct {
+	pattern  *regexp.Regexp
+	policy   Policy
+	server   *http.Server
+	listener net.Listener
+	messages chan httpMessage
+}
+
+func NewHTTPListener(addr string, pattern *regexp.Regexp, policy Policy) (*HTTPListener, error) {
+	if pattern == nil {
+		pattern = DefaultPattern
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &HTTPListener{
+		pattern:  pattern,
+		policy:   policy,
+		listener: listener,
+		messages: make(chan httpMessage, 16),
+	}
+
+	l.server = &http.Server{
+		Handler:           http.HandlerFunc(l.handle),
+		ReadHeaderTimeout: time.Second * 10,
+	}
+
+	go l.server.Serve(listener)
+
+	return l, nil
+}
+
+func (l *HTTPListener) Addr() string {
+	return l.listener.Addr().String()
+}
+
+func (l *HTTPListener) Close() error {
+	return l.server.Close()
+}
+
+func (l *HTTPListener) Policy() Policy {
+	return l.policy
+}
+
+func (l *HTTPListener) Code(ctx context.Context, req Request) (string, error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case message := <-l.messages:
+			if message.receivedAt.Before(req.SentAt) {
+				continue
+			}
+
+			return message.code, nil
+		}
+	}
+}
+
+func (l *HTTPListener) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	text, err := readMessage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	code, ok := Extract(l.pattern, text)
+	if !ok {
+		http.Error(w, ErrNoCode.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	select {
+	case l.messages <- httpMessage{code: code, receivedAt: time.Now()}:
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		http.Error(w, "too many pending messages", http.StatusServiceUnavailable)
+	}
+}
+
+func readMessage(r *http.Request) (string, error) {
+	contentType := r.Header.Get("Content-Type")
+
+	switch {
+	case strings.HasPrefix(contentType, "application/x-www-form-urlencoded"),
+		strings.HasPrefix(contentType, "multipart/form-data"):
+		if err := r.ParseMultipartForm(1 << 16); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+			return "", err
+		}
+
+		for _, field := range httpMessageFields {
+			if value := r.FormValue(field); value != "" {
+				return value, nil
+			}
+		}
+
+		return "", ErrNoCode
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<16))
+	if err != nil {
+		return "", err
+	}
+
+	if strings.HasPrefix(contentType, "application/json") {
+		var fields map[string]any
+		if err := json.Unmarshal(body, &fields); err != nil {
+			return "", err
+		}
+
+		for _, field := range httpMessageFields {
+			if value, ok := fields[field].(string); ok && value != "" {
+				return value, nil
+			}
+		}
+
+		return "", ErrNoCode
+	}
+
+	return string(body), nil
+}
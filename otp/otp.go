@@ -0,0 +1,93 @@
+package otp
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"time"
+)
+
+var (
+	ErrTimeout = errors.New("otp timed out")
+	ErrResend  = errors.New("otp resend requested")
+	ErrNoCode  = errors.New("no otp code found")
+
+	DefaultPattern = regexp.MustCompile(`\b(\d{6})\b`)
+)
+
+// Request describes the challenge a Provider is asked to answer.
+type Request struct {
+	// ContactPoint is the masked destination the code was sent to
+	ContactPoint string
+	// Medium is how the code was delivered (e.g. SMS)
+	Medium string
+	// SentAt is when the code was sent, or when the last code was rejected;
+	// providers ignore anything older
+	SentAt time.Time
+	// Attempt is how many codes were already submitted for the challenge
+	Attempt int
+	// RemainingAttempts is how many codes can still be submitted after a
+	// rejected one, or -1 when unknown
+	RemainingAttempts int
+	// Rejected is set when the last code entered for this request was wrong,
+	// and not when a new code was just sent
+	Rejected bool
+	// RejectedCodes are the codes already rejected for the challenge
+	RejectedCodes []string
+}
+
+// Policy bounds how long a provider waits for a code and how many times the
+// code may be re-sent when it does not arrive.
+type Policy struct {
+	Timeout time.Duration
+	Resends int
+}
+
+// Provider supplies OTP codes for login and step-up challenges.
+type Provider interface {
+	// Code blocks until the code for req is available. It returns ErrResend
+	// when the code should be sent again.
+	Code(ctx context.Context, req Request) (string, error)
+	Policy() Policy
+}
+
+// Wait asks p for the code of an already sent request, returning ErrTimeout
+// when the provider's policy timeout passes first.
+func Wait(ctx context.Context, p Provider, req Request) (string, error) {
+	waitCtx := ctx
+	if timeout := p.Policy().Timeout; timeout > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	code, err := p.Code(waitCtx, req)
+	if err != nil {
+		if ctx.Err() == nil && errors.Is(waitCtx.Err(), context.DeadlineExceeded) {
+			return "", ErrTimeout
+		}
+
+		return "", err
+	}
+
+	return code, nil
+}
+
+// Extract returns the first code matched by pattern in text. The first
+// capture group is used when the pattern has one.
+func Extract(pattern *regexp.Regexp, text string) (string, bool) {
+	if pattern == nil {
+		pattern = DefaultPattern
+	}
+
+	match := pattern.FindStringSubmatch(text)
+	if match == nil {
+		return "", false
+	}
+
+	if len(match) > 1 {
+		return match[1], true
+	}
+
+	return match[0], true
+}
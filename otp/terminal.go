@@ -0,0 +1,110 @@
+package otp
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// LineReader shares the lines of an io.Reader, like stdin, between every
+// prompt. A single goroutine reads them, so a prompt that gives up on
+// cancellation leaves the line being typed to the next prompt instead of
+// losing it or input buffered after it.
+type LineReader struct {
+	r     *bufio.Reader
+	once  sync.Once
+	lines chan string
+	err   error
+}
+
+func NewLineReader(r io.Reader) *LineReader {
+	return &LineReader{
+		r:     bufio.NewReader(r),
+		lines: make(chan string),
+	}
+}
+
+func (l *LineReader) read() {
+	for {
+		line, err := l.r.ReadString('\n')
+		if line != "" || err == nil {
+			l.lines <- strings.TrimSpace(line)
+		}
+
+		if err != nil {
+			l.err = err
+			close(l.lines)
+			return
+		}
+	}
+}
+
+// ReadLine returns the next line without its surrounding spaces.
+func (l *LineReader) ReadLine(ctx context.Context) (string, error) {
+	l.once.Do(func() { go l.read() })
+
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case line, ok := <-l.lines:
+		if !ok {
+			return "", l.err
+		}
+
+		return line, nil
+	}
+}
+
+// Terminal prompts for the code on an interactive terminal. Entering "r"
+// requests a new code.
+type Terminal struct {
+	in     *LineReader
+	out    io.Writer
+	policy Policy
+}
+
+func NewTerminal(in *LineReader, out io.Writer, policy Policy) *Terminal {
+	return &Terminal{
+		in:     in,
+		out:    out,
+		policy: policy,
+	}
+}
+
+func (t *Terminal) Policy() Policy {
+	return t.policy
+}
+
+func (t *Terminal) Code(ctx context.Context, req Request) (string, error) {
+	medium := req.Medium
+	if medium == "" {
+		medium = "SMS"
+	}
+
+	prompt := fmt.Sprintf("Enter %s OTP", medium)
+	if req.ContactPoint != "" {
+		prompt += " sent to " + req.ContactPoint
+	}
+	if req.Rejected {
+		prompt = "Incorrect code. " + prompt
+	}
+	if req.Attempt > 0 && req.RemainingAttempts >= 0 {
+		prompt += fmt.Sprintf(" (%d attempts remaining)", req.RemainingAttempts)
+	}
+	fmt.Fprintf(t.out, "[?] %s (r to resend): ", prompt)
+
+	code, err := t.in.ReadLine(ctx)
+	if err != nil {
+		fmt.Fprintln(t.out)
+		return "", err
+	}
+
+	if code == "" || strings.EqualFold(code, "r") {
+		return "", ErrResend
+	}
+
+	return code, nil
+}
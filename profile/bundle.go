@@ -0,0 +1,504 @@
+package profile
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	bundleFormat  = "eno-profile"
+	BundleVersion = 1
+
+	manifestEntry  = "manifest.json"
+	documentPrefix = "documents/"
+	filePrefix     = "files/"
+)
+
+var (
+	ErrInvalidBundle = errors.New("invalid bundle")
+
+	// The key derivation of a bundle is read from its header before it can
+	// be authenticated, so its cost is bounded
+	minBundleIterations = kdfIterations
+	maxBundleIterations = 10 * kdfIterations
+)
+
+// bundleHeader is the unencrypted first line of a bundle. It is
+// authenticated along with the archive, so it cannot be altered either.
+type bundleHeader struct {
+	Format     string    `json:"format"`
+	Version    int       `json:"version"`
+	CreatedAt  time.Time `json:"createdAt"`
+	KDF        string    `json:"kdf"`
+	Iterations int       `json:"iterations"`
+	Salt       []byte    `json:"salt"`
+	// Nonce is the base the nonce of each sealed chunk is derived from
+	Nonce []byte `json:"nonce"`
+}
+
+// Manifest describes the contents of a bundle.
+type Manifest struct {
+	Version   int             `json:"version"`
+	Profile   string          `json:"profile"`
+	CreatedAt time.Time       `json:"createdAt"`
+	Entries   []ManifestEntry `json:"entries"`
+}
+
+type ManifestEntry struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+type ExportOptions struct {
+	// Documents lists the documents to export, all of them if nil
+	Documents []string
+	// Directories lists the directories inside the profile's Dir to export
+	Directories []string
+	// Skip leaves out files and directories, given their slash separated
+	// path relative to Dir
+	Skip func(rel string) bool
+}
+
+// Export writes p to w as a bundle encrypted with passphrase. Bundles are a
+// gzipped tar of the documents and directories, followed by a manifest of
+// their checksums, sealed with AES-256-GCM in chunks so they are streamed
+// rather than held in memory. The first line is an unencrypted header tagging
+// the bundle version and key derivation.
+func Export(w io.Writer, p *Profile, passphrase string, opts ExportOptions) (Manifest, error) {
+	manifest := Manifest{
+		Version:   BundleVersion,
+		Profile:   p.Name,
+		CreatedAt: time.Now().UTC(),
+		Entries:   []ManifestEntry{},
+	}
+
+	documents := opts.Documents
+	if documents == nil {
+		var err error
+		documents, err = p.Store.List()
+		if err != nil {
+			return manifest, err
+		}
+	}
+
+	header := bundleHeader{
+		Format:     bundleFormat,
+		Version:    BundleVersion,
+		CreatedAt:  manifest.CreatedAt,
+		KDF:        "pbkdf2-sha256",
+		Iterations: kdfIterations,
+		Salt:       make([]byte, saltSize),
+	}
+	rand.Read(header.Salt)
+
+	aead, err := NewCipher(passphrase, header.Salt, header.Iterations)
+	if err != nil {
+		return manifest, err
+	}
+
+	header.Nonce = make([]byte, aead.NonceSize())
+	rand.Read(header.Nonce)
+
+	headerLine, err := json.Marshal(header)
+	if err != nil {
+		return manifest, err
+	}
+
+	if _, err := w.Write(append(headerLine, '\n')); err != nil {
+		return manifest, err
+	}
+
+	sw := newSealWriter(w, aead, header.Nonce, headerLine)
+	gz := gzip.NewWriter(sw)
+	tw := tar.NewWriter(gz)
+
+	writeEntry := func(name string, size int64, r io.Reader) (ManifestEntry, error) {
+		err := tw.WriteHeader(&tar.Header{
+			Name:    name,
+			Mode:    0600,
+			Size:    size,
+			ModTime: manifest.CreatedAt,
+		})
+		if err != nil {
+			return ManifestEntry{}, err
+		}
+
+		h := sha256.New()
+		if _, err := io.CopyN(io.MultiWriter(tw, h), r, size); err != nil {
+			return ManifestEntry{}, fmt.Errorf("%s: %w", name, err)
+		}
+
+		return ManifestEntry{
+			Name:   name,
+			Size:   size,
+			SHA256: hex.EncodeToString(h.Sum(nil)),
+		}, nil
+	}
+
+	add := func(name string, size int64, r io.Reader) error {
+		entry, err := writeEntry(name, size, r)
+		if err != nil {
+			return err
+		}
+
+		manifest.Entries = append(manifest.Entries, entry)
+		return nil
+	}
+
+	for _, name := range documents {
+		data, err := p.Store.Read(name)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return manifest, fmt.Errorf("%s: %w", name, err)
+		}
+
+		if err := add(documentPrefix+name, int64(len(data)), bytes.NewReader(data)); err != nil {
+			return manifest, err
+		}
+	}
+
+	for _, dir := range opts.Directories {
+		root := filepath.Join(p.Dir, dir)
+		err := filepath.WalkDir(root, func(file string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) && file == root {
+					return nil
+				}
+
+				return err
+			}
+
+			rel, err := filepath.Rel(p.Dir, file)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+
+			if opts.Skip != nil && opts.Skip(rel) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+
+				return nil
+			}
+
+			if !d.Type().IsRegular() {
+				return nil
+			}
+
+			f, err := os.Open(file)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			info, err := f.Stat()
+			if err != nil {
+				return err
+			}
+
+			return add(filePrefix+rel, info.Size(), f)
+		})
+		if err != nil {
+			return manifest, err
+		}
+	}
+
+	// The manifest comes last as the checksums are computed while streaming
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return manifest, err
+	}
+
+	if _, err := writeEntry(manifestEntry, int64(len(manifestData)), bytes.NewReader(manifestData)); err != nil {
+		return manifest, err
+	}
+
+	if err := tw.Close(); err != nil {
+		return manifest, err
+	}
+
+	if err := gz.Close(); err != nil {
+		return manifest, err
+	}
+
+	return manifest, sw.Close()
+}
+
+// Bundle is a decrypted bundle whose contents match its manifest. Its files
+// are staged in a temporary directory until Close.
+type Bundle struct {
+	Manifest Manifest
+
+	documents map[string][]byte
+	staging   string
+}
+
+// ReadBundle decrypts a bundle written by Export and verifies it against its
+// manifest. Files are staged on disk rather than held in memory, so the
+// Bundle must be closed.
+func ReadBundle(r io.Reader, passphrase string) (*Bundle, error) {
+	br := bufio.NewReader(r)
+	headerLine, err := br.ReadBytes('\n')
+	if err != nil {
+		return nil, fmt.Errorf("%w: read header: %w", ErrInvalidBundle, err)
+	}
+	headerLine = headerLine[:len(headerLine)-1]
+
+	var header bundleHeader
+	if err := json.Unmarshal(headerLine, &header); err != nil || header.Format != bundleFormat {
+		return nil, fmt.Errorf("%w: not an eno profile bundle", ErrInvalidBundle)
+	}
+
+	if header.Version != BundleVersion {
+		return nil, fmt.Errorf("%w: bundle version %d (supported is %d)", ErrUnsupportedVersion, header.Version, BundleVersion)
+	}
+
+	if header.Iterations < minBundleIterations || header.Iterations > maxBundleIterations {
+		return nil, fmt.Errorf("%w: %d key derivation iterations (allowed %d to %d)", ErrInvalidBundle, header.Iterations, minBundleIterations, maxBundleIterations)
+	}
+
+	if len(header.Salt) != saltSize {
+		return nil, fmt.Errorf("%w: invalid salt", ErrInvalidBundle)
+	}
+
+	aead, err := NewCipher(passphrase, header.Salt, header.Iterations)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(header.Nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("%w: invalid nonce", ErrInvalidBundle)
+	}
+
+	staging, err := os.MkdirTemp("", "eno-bundle-")
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Bundle{documents: map[string][]byte{}, staging: staging}
+	if err := b.read(newOpenReader(br, aead, header.Nonce, headerLine)); err != nil {
+		b.Close()
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// read extracts the archive into b, checking every entry against the
+// manifest.
+func (b *Bundle) read(r io.Reader) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return bundleError(err)
+	}
+
+	read := map[string]ManifestEntry{}
+	var manifestData []byte
+	tr := tar.NewReader(gz)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return bundleError(err)
+		}
+
+		if h.Name == manifestEntry {
+			if manifestData, err = io.ReadAll(tr); err != nil {
+				return bundleError(err)
+			}
+			continue
+		}
+
+		if !validEntry(h.Name) {
+			return fmt.Errorf("%w: unsafe path %s", ErrInvalidBundle, h.Name)
+		}
+
+		if _, ok := read[h.Name]; ok {
+			return fmt.Errorf("%w: duplicate entry %s", ErrInvalidBundle, h.Name)
+		}
+
+		entry, err := b.extract(h.Name, tr)
+		if err != nil {
+			return err
+		}
+		read[h.Name] = entry
+	}
+
+	// The rest of the stream is read so its last chunk is authenticated
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		return bundleError(err)
+	}
+
+	if err := json.Unmarshal(manifestData, &b.Manifest); err != nil {
+		return fmt.Errorf("%w: manifest: %w", ErrInvalidBundle, err)
+	}
+
+	if len(b.Manifest.Entries) != len(read) {
+		return fmt.Errorf("%w: entries do not match the manifest", ErrInvalidBundle)
+	}
+
+	for _, entry := range b.Manifest.Entries {
+		if read[entry.Name] != entry {
+			return fmt.Errorf("%w: checksum mismatch for %s", ErrInvalidBundle, entry.Name)
+		}
+	}
+
+	return nil
+}
+
+// extract keeps a document in memory or stages a file, returning its size and
+// checksum.
+func (b *Bundle) extract(name string, r io.Reader) (ManifestEntry, error) {
+	h := sha256.New()
+	var size int64
+
+	if document, ok := strings.CutPrefix(name, documentPrefix); ok {
+		data, err := io.ReadAll(io.TeeReader(r, h))
+		if err != nil {
+			return ManifestEntry{}, bundleError(err)
+		}
+
+		b.documents[document] = data
+		size = int64(len(data))
+	} else {
+		rel, _ := strings.CutPrefix(name, filePrefix)
+		file := filepath.Join(b.staging, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+			return ManifestEntry{}, err
+		}
+
+		f, err := os.OpenFile(file, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err != nil {
+			return ManifestEntry{}, err
+		}
+		defer f.Close()
+
+		if size, err = io.Copy(io.MultiWriter(f, h), r); err != nil {
+			return ManifestEntry{}, bundleError(err)
+		}
+	}
+
+	return ManifestEntry{
+		Name:   name,
+		Size:   size,
+		SHA256: hex.EncodeToString(h.Sum(nil)),
+	}, nil
+}
+
+// bundleError marks archive errors as ErrInvalidBundle, keeping the errors of
+// the decryption as they are.
+func bundleError(err error) error {
+	if errors.Is(err, ErrInvalidBundle) || errors.Is(err, ErrWrongPassphrase) {
+		return err
+	}
+
+	return fmt.Errorf("%w: %w", ErrInvalidBundle, err)
+}
+
+// Close removes the staged files.
+func (b *Bundle) Close() error {
+	return os.RemoveAll(b.staging)
+}
+
+// validEntry reports whether restoring an entry stays inside the profile.
+func validEntry(name string) bool {
+	if document, ok := strings.CutPrefix(name, documentPrefix); ok {
+		return document != "" && document != keyName && !strings.HasPrefix(document, ".") && !strings.ContainsAny(document, `/\`)
+	}
+
+	if rel, ok := strings.CutPrefix(name, filePrefix); ok {
+		return filepath.IsLocal(filepath.FromSlash(rel))
+	}
+
+	return false
+}
+
+// Restore writes the bundle into p, replacing documents and files that
+// already exist.
+func (b *Bundle) Restore(p *Profile) error {
+	for _, entry := range b.Manifest.Entries {
+		if name, ok := strings.CutPrefix(entry.Name, documentPrefix); ok {
+			if err := p.Store.Write(name, b.documents[name]); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			continue
+		}
+
+		rel, _ := strings.CutPrefix(entry.Name, filePrefix)
+		if err := copyFile(filepath.Join(b.staging, filepath.FromSlash(rel)), filepath.Join(p.Dir, filepath.FromSlash(rel))); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func copyFile(src string, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
+
+// Documents returns the names of the documents in the manifest.
+func (m Manifest) Documents() []string {
+	names := []string{}
+	for _, entry := range m.Entries {
+		if name, ok := strings.CutPrefix(entry.Name, documentPrefix); ok {
+			names = append(names, name)
+		}
+	}
+
+	slices.Sort(names)
+	return names
+}
+
+// Files returns the number of files in the manifest.
+func (m Manifest) Files() int {
+	count := 0
+	for _, entry := range m.Entries {
+		if _, ok := strings.CutPrefix(entry.Name, filePrefix); ok {
+			count++
+		}
+	}
+
+	return count
+}
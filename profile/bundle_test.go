@@ -0,0 +1,353 @@
+package profile
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestBundleRoundTrip(t *testing.T) {
+	store := NewMemoryStore()
+	store.Write(Device, []byte(`{"id":"a"}`))
+	store.Write(Cookies, []byte(`[]`))
+	p := New("source", t.TempDir(), store)
+
+	// Incompressible and large enough to span several sealed chunks
+	large := make([]byte, 2*chunkSize+1)
+	rand.Read(large)
+	writeFile(t, filepath.Join(p.Dir, "user_data", "Default", "Preferences"), large)
+	writeFile(t, filepath.Join(p.Dir, "user_data", "Cache", "data"), []byte("cache"))
+	writeFile(t, filepath.Join(p.Dir, "cards", "empty.csv"), nil)
+
+	var buf bytes.Buffer
+	manifest, err := Export(&buf, p, "passphrase", ExportOptions{
+		Directories: []string{"user_data", "cards", "missing"},
+		Skip: func(rel string) bool {
+			return rel == "user_data/Cache"
+		},
+	})
+	if err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	if got := manifest.Documents(); !slices.Equal(got, []string{Cookies, Device}) {
+		t.Errorf("Documents() = %v, want %v", got, []string{Cookies, Device})
+	}
+	if manifest.Files() != 2 {
+		t.Errorf("Files() = %d, want 2", manifest.Files())
+	}
+
+	bundle, err := ReadBundle(bytes.NewReader(buf.Bytes()), "passphrase")
+	if err != nil {
+		t.Fatalf("ReadBundle() error = %v", err)
+	}
+	defer bundle.Close()
+
+	if bundle.Manifest.Profile != "source" {
+		t.Errorf("Manifest.Profile = %q, want %q", bundle.Manifest.Profile, "source")
+	}
+
+	target := New("target", t.TempDir(), NewMemoryStore())
+	if err := bundle.Restore(target); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+
+	if data, _ := target.Store.Read(Device); string(data) != `{"id":"a"}` {
+		t.Errorf("restored %s = %s, want %s", Device, data, `{"id":"a"}`)
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(target.Dir, "user_data", "Default", "Preferences")); !bytes.Equal(data, large) {
+		t.Errorf("restored Preferences has %d bytes, want %d", len(data), len(large))
+	}
+
+	if _, err := os.Stat(filepath.Join(target.Dir, "cards", "empty.csv")); err != nil {
+		t.Errorf("empty file was not restored: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(target.Dir, "user_data", "Cache")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("skipped directory was restored: %v", err)
+	}
+
+	staging := bundle.staging
+	bundle.Close()
+	if _, err := os.Stat(staging); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Close() left the staging directory: %v", err)
+	}
+}
+
+func TestValidEntry(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"documents/cookies", true},
+		{"files/user_data/Default/Preferences", true},
+		{"documents/" + keyName, false},
+		{"documents/.lock", false},
+		{"documents/", false},
+		{"documents/a/b", false},
+		{`documents/a\b`, false},
+		{"files/../x", false},
+		{"files/a/../../x", false},
+		{"files//etc/passwd", false},
+		{"files/", false},
+		{"other/x", false},
+		{"manifest", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validEntry(tt.name); got != tt.want {
+				t.Errorf("validEntry(%q) = %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+// bundleEntry is an entry of a crafted bundle.
+type bundleEntry struct {
+	name string
+	data string
+}
+
+// craftBundle seals entries as a bundle, with a manifest listing manifest
+// unless it is nil. It derives its key with a single iteration, which reading
+// bundles is allowed to accept for the rest of the test, to keep tests fast.
+func craftBundle(t *testing.T, passphrase string, entries []bundleEntry, manifest []bundleEntry) []byte {
+	t.Helper()
+
+	minIterations := minBundleIterations
+	minBundleIterations = 1
+	t.Cleanup(func() { minBundleIterations = minIterations })
+
+	header := bundleHeader{
+		Format:     bundleFormat,
+		Version:    BundleVersion,
+		CreatedAt:  time.Now().UTC(),
+		KDF:        "pbkdf2-sha256",
+		Iterations: 1,
+		Salt:       make([]byte, 16),
+		Nonce:      make([]byte, 12),
+	}
+
+	aead, err := NewCipher(passphrase, header.Salt, header.Iterations)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	headerLine, err := json.Marshal(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	buf.Write(append(headerLine, '\n'))
+
+	sw := newSealWriter(&buf, aead, header.Nonce, headerLine)
+	gz := gzip.NewWriter(sw)
+	tw := tar.NewWriter(gz)
+
+	add := func(name string, data []byte) {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(data))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, entry := range entries {
+		add(entry.name, []byte(entry.data))
+	}
+
+	if manifest != nil {
+		m := Manifest{Version: BundleVersion, Profile: "crafted", Entries: []ManifestEntry{}}
+		for _, entry := range manifest {
+			sum := sha256.Sum256([]byte(entry.data))
+			m.Entries = append(m.Entries, ManifestEntry{
+				Name:   entry.name,
+				Size:   int64(len(entry.data)),
+				SHA256: hex.EncodeToString(sum[:]),
+			})
+		}
+
+		data, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		add(manifestEntry, data)
+	}
+
+	for _, c := range []interface{ Close() error }{tw, gz, sw} {
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return buf.Bytes()
+}
+
+func TestReadBundle(t *testing.T) {
+	device := bundleEntry{"documents/device", `{"id":"a"}`}
+	file := bundleEntry{"files/cards/a.csv", "a,b"}
+
+	tests := []struct {
+		name     string
+		entries  []bundleEntry
+		manifest []bundleEntry
+		wantErr  error
+	}{
+		{
+			name:     "valid",
+			entries:  []bundleEntry{device, file},
+			manifest: []bundleEntry{device, file},
+		},
+		{
+			name:     "checksum mismatch",
+			entries:  []bundleEntry{device, {file.name, "a,c"}},
+			manifest: []bundleEntry{device, file},
+			wantErr:  ErrInvalidBundle,
+		},
+		{
+			name:     "unsafe path",
+			entries:  []bundleEntry{device, {"files/../escape", "x"}},
+			manifest: []bundleEntry{device, {"files/../escape", "x"}},
+			wantErr:  ErrInvalidBundle,
+		},
+		{
+			name:     "encryption key",
+			entries:  []bundleEntry{{"documents/" + keyName, "{}"}},
+			manifest: []bundleEntry{{"documents/" + keyName, "{}"}},
+			wantErr:  ErrInvalidBundle,
+		},
+		{
+			name:     "entry missing from the manifest",
+			entries:  []bundleEntry{device, file},
+			manifest: []bundleEntry{device},
+			wantErr:  ErrInvalidBundle,
+		},
+		{
+			name:     "entry missing from the archive",
+			entries:  []bundleEntry{device},
+			manifest: []bundleEntry{device, file},
+			wantErr:  ErrInvalidBundle,
+		},
+		{
+			name:     "duplicate entry",
+			entries:  []bundleEntry{device, device},
+			manifest: []bundleEntry{device, device},
+			wantErr:  ErrInvalidBundle,
+		},
+		{
+			name:    "missing manifest",
+			entries: []bundleEntry{device},
+			wantErr: ErrInvalidBundle,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := craftBundle(t, "passphrase", tt.entries, tt.manifest)
+
+			bundle, err := ReadBundle(bytes.NewReader(data), "passphrase")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ReadBundle() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if err == nil {
+				bundle.Close()
+			}
+		})
+	}
+}
+
+func TestReadBundleTampered(t *testing.T) {
+	device := bundleEntry{"documents/device", `{"id":"a"}`}
+	// Incompressible data so the archive spans several sealed chunks
+	random := make([]byte, 2*chunkSize)
+	rand.Read(random)
+	file := bundleEntry{"files/user_data/blob", string(random)}
+
+	data := craftBundle(t, "passphrase", []bundleEntry{device, file}, []bundleEntry{device, file})
+	headerEnd := bytes.IndexByte(data, '\n') + 1
+
+	if _, err := ReadBundle(bytes.NewReader(data), "wrong"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("ReadBundle() with the wrong passphrase error = %v, want %v", err, ErrWrongPassphrase)
+	}
+
+	// Cut inside the first chunk, at the chunk boundary and inside the last
+	sealedChunk := chunkSize + 16
+	for _, end := range []int{headerEnd + 100, headerEnd + sealedChunk, len(data) - 1} {
+		if _, err := ReadBundle(bytes.NewReader(data[:end]), "passphrase"); err == nil {
+			t.Errorf("ReadBundle() of a bundle truncated to %d of %d bytes succeeded", end, len(data))
+		}
+	}
+
+	flipped := slices.Clone(data)
+	flipped[headerEnd+sealedChunk+10] ^= 1
+	if _, err := ReadBundle(bytes.NewReader(flipped), "passphrase"); !errors.Is(err, ErrInvalidBundle) {
+		t.Errorf("ReadBundle() of a modified bundle error = %v, want %v", err, ErrInvalidBundle)
+	}
+
+	// The header is authenticated along with the archive
+	altered := bytes.Replace(slices.Clone(data), []byte(`"version":1`), []byte(`"version":1 `), 1)
+	if _, err := ReadBundle(bytes.NewReader(altered), "passphrase"); err == nil {
+		t.Error("ReadBundle() of a bundle with an altered header succeeded")
+	}
+}
+
+func TestReadBundleKeyDerivation(t *testing.T) {
+	tests := []struct {
+		name       string
+		iterations int
+		salt       []byte
+	}{
+		{"too few iterations", kdfIterations - 1, make([]byte, saltSize)},
+		{"too many iterations", 10*kdfIterations + 1, make([]byte, saltSize)},
+		{"no salt", kdfIterations, nil},
+		{"short salt", kdfIterations, make([]byte, 4)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headerLine, err := json.Marshal(bundleHeader{
+				Format:     bundleFormat,
+				Version:    BundleVersion,
+				KDF:        "pbkdf2-sha256",
+				Iterations: tt.iterations,
+				Salt:       tt.salt,
+				Nonce:      make([]byte, 12),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Rejected before deriving a key, so no archive is needed
+			if _, err := ReadBundle(bytes.NewReader(append(headerLine, '\n')), "passphrase"); !errors.Is(err, ErrInvalidBundle) {
+				t.Errorf("ReadBundle() error = %v, want %v", err, ErrInvalidBundle)
+			}
+		})
+	}
+}
+
+func writeFile(t *testing.T, name string, data []byte) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(name), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(name, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,182 @@
+package profile
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+const (
+	// keyName holds the salt and key check of an EncryptedStore, unencrypted
+	keyName = "encryption"
+
+	kdfIterations = 600_000
+	saltSize      = 16
+	keyCheck      = "eno"
+)
+
+var (
+	ErrWrongPassphrase = errors.New("wrong passphrase")
+)
+
+type keyParams struct {
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Check      []byte `json:"check"`
+}
+
+type sealed struct {
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// EncryptedStore encrypts every document of another Store with AES-256-GCM
+// under a key derived from a passphrase. Documents are bound to their name,
+// so one cannot be swapped for another.
+//
+// It is only available to programs using the package: the cli keeps its
+// profiles in a plain FileStore.
+type EncryptedStore struct {
+	store Store
+	aead  cipher.AEAD
+}
+
+// NewEncryptedStore opens store with passphrase, setting up a new key if store
+// has never been encrypted. It returns ErrWrongPassphrase if passphrase does
+// not match the existing key.
+func NewEncryptedStore(store Store, passphrase string) (*EncryptedStore, error) {
+	var params keyParams
+	data, err := store.Read(keyName)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &params); err != nil {
+			return nil, fmt.Errorf("%s: %w", keyName, err)
+		}
+	case errors.Is(err, ErrNotFound):
+		params = keyParams{
+			KDF:        "pbkdf2-sha256",
+			Iterations: kdfIterations,
+			Salt:       make([]byte, saltSize),
+		}
+		rand.Read(params.Salt)
+	default:
+		return nil, err
+	}
+
+	aead, err := NewCipher(passphrase, params.Salt, params.Iterations)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &EncryptedStore{store: store, aead: aead}
+
+	if params.Check != nil {
+		if _, err := s.open(keyName, params.Check); err != nil {
+			return nil, ErrWrongPassphrase
+		}
+
+		return s, nil
+	}
+
+	params.Check = s.seal(keyName, []byte(keyCheck))
+	data, err = json.MarshalIndent(params, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := store.Write(keyName, data); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// NewCipher derives an AES-256-GCM cipher from passphrase.
+func NewCipher(passphrase string, salt []byte, iterations int) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func (s *EncryptedStore) seal(name string, plaintext []byte) []byte {
+	nonce := make([]byte, s.aead.NonceSize())
+	rand.Read(nonce)
+	return s.aead.Seal(nonce, nonce, plaintext, []byte(name))
+}
+
+func (s *EncryptedStore) open(name string, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < s.aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	nonce, ciphertext := ciphertext[:s.aead.NonceSize()], ciphertext[s.aead.NonceSize():]
+	return s.aead.Open(nil, nonce, ciphertext, []byte(name))
+}
+
+func (s *EncryptedStore) Read(name string) ([]byte, error) {
+	data, err := s.store.Read(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc sealed
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	plaintext, err := s.open(name, append(doc.Nonce, doc.Ciphertext...))
+	if err != nil {
+		return nil, fmt.Errorf("decrypt %s: %w", name, err)
+	}
+
+	return plaintext, nil
+}
+
+func (s *EncryptedStore) Write(name string, data []byte) error {
+	if name == keyName {
+		return fmt.Errorf("%s is reserved", name)
+	}
+
+	ciphertext := s.seal(name, data)
+	nonceSize := s.aead.NonceSize()
+
+	contents, err := json.MarshalIndent(sealed{
+		Nonce:      ciphertext[:nonceSize],
+		Ciphertext: ciphertext[nonceSize:],
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return s.store.Write(name, contents)
+}
+
+func (s *EncryptedStore) Delete(name string) error {
+	return s.store.Delete(name)
+}
+
+func (s *EncryptedStore) List() ([]string, error) {
+	names, err := s.store.List()
+	if err != nil {
+		return nil, err
+	}
+
+	return slices.DeleteFunc(names, func(name string) bool {
+		return name == keyName
+	}), nil
+}
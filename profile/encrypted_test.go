@@ -0,0 +1,60 @@
+package profile
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestEncryptedStore(t *testing.T) {
+	backing := NewMemoryStore()
+	s, err := NewEncryptedStore(backing, "passphrase")
+	if err != nil {
+		t.Fatalf("NewEncryptedStore() error = %v", err)
+	}
+
+	if err := s.Write(Device, []byte(`{"id":"a"}`)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	if raw, _ := backing.Read(Device); bytes.Contains(raw, []byte(`"id"`)) {
+		t.Error("document is saved in the clear")
+	}
+
+	if err := s.Write(keyName, []byte("{}")); err == nil {
+		t.Errorf("Write(%q) succeeded, want an error", keyName)
+	}
+
+	names, err := s.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if !slices.Equal(names, []string{Device}) {
+		t.Errorf("List() = %v, want %v", names, []string{Device})
+	}
+
+	// A document copied under another name does not decrypt
+	raw, _ := backing.Read(Device)
+	backing.Write(Cookies, raw)
+	if _, err := s.Read(Cookies); err == nil {
+		t.Error("Read() of a document copied under another name succeeded")
+	}
+
+	reopened, err := NewEncryptedStore(backing, "passphrase")
+	if err != nil {
+		t.Fatalf("NewEncryptedStore() reopen error = %v", err)
+	}
+
+	data, err := reopened.Read(Device)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if string(data) != `{"id":"a"}` {
+		t.Errorf("Read() = %s, want %s", data, `{"id":"a"}`)
+	}
+
+	if _, err := NewEncryptedStore(backing, "wrong"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("NewEncryptedStore() with the wrong passphrase error = %v, want %v", err, ErrWrongPassphrase)
+	}
+}
@@ -0,0 +1,142 @@
+package profile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const lockName = ".lock"
+
+// FileStore keeps each document as <name>.json in a directory. Writes go
+// through a temporary file that is renamed into place, and every operation
+// holds a lock on the directory so several processes can share a profile.
+type FileStore struct {
+	dir string
+}
+
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	return &FileStore{dir: dir}, nil
+}
+
+func (s *FileStore) Dir() string {
+	return s.dir
+}
+
+func (s *FileStore) path(name string) string {
+	return filepath.Join(s.dir, name+".json")
+}
+
+// lock takes the directory lock, shared for reads and exclusive for writes.
+func (s *FileStore) lock(exclusive bool) (func(), error) {
+	f, err := os.OpenFile(filepath.Join(s.dir, lockName), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lockFile(f, exclusive); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("lock %s: %w", s.dir, err)
+	}
+
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
+
+func (s *FileStore) Read(name string) ([]byte, error) {
+	unlock, err := s.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	data, err := os.ReadFile(s.path(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%s: %w", s.path(name), ErrNotFound)
+		}
+
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (s *FileStore) Write(name string, data []byte) error {
+	unlock, err := s.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	f, err := os.CreateTemp(s.dir, "."+name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), s.path(name))
+}
+
+func (s *FileStore) Delete(name string) error {
+	unlock, err := s.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	err = os.Remove(s.path(name))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+func (s *FileStore) List() ([]string, error) {
+	unlock, err := s.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".json")
+		if entry.IsDir() || !ok || strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+	return names, nil
+}
@@ -0,0 +1,14 @@
+//go:build !unix && !windows
+
+package profile
+
+import "os"
+
+// Platforms without file locking rely on atomic renames alone.
+func lockFile(f *os.File, exclusive bool) error {
+	return nil
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
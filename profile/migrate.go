@@ -0,0 +1,112 @@
+package profile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrUnsupportedVersion = errors.New("unsupported schema version")
+)
+
+// Names of the documents kept in a profile.
+const (
+	Credentials = "credentials"
+	Device      = "device"
+	Cookies     = "cookies"
+	Express     = "express"
+	StepUps     = "stepup"
+)
+
+// Migration upgrades the data of a document by one schema version.
+type Migration func(data json.RawMessage) (json.RawMessage, error)
+
+// migrations lists, per document, the steps from each version to the next.
+// The current version of a document is the number of its migrations, and
+// documents without any are at version 0.
+//
+// Version 0 is the unversioned JSON written by earlier releases. Version 1
+// wraps it in an envelope without changing the data.
+var migrations = map[string][]Migration{
+	Credentials: {expect('{')},
+	Device:      {expect('{')},
+	Cookies:     {expect('[')},
+	Express:     {expect('{')},
+}
+
+// expect checks that unversioned data is the JSON object or array the
+// document has always been.
+func expect(delim byte) Migration {
+	return func(data json.RawMessage) (json.RawMessage, error) {
+		var v any
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+
+		_, isObject := v.(map[string]any)
+		_, isArray := v.([]any)
+		if (delim == '{' && !isObject) || (delim == '[' && !isArray) {
+			return nil, fmt.Errorf("expected a JSON %s", map[byte]string{'{': "object", '[': "array"}[delim])
+		}
+
+		return data, nil
+	}
+}
+
+// CurrentVersion returns the schema version documents called name are saved
+// with.
+func CurrentVersion(name string) int {
+	return len(migrations[name])
+}
+
+type envelope struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	Data          json.RawMessage `json:"data"`
+}
+
+// decode splits a stored document into its schema version and data.
+// Documents that are not an envelope are unversioned.
+func decode(contents []byte) (int, json.RawMessage) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(contents, &fields); err == nil && len(fields) == 2 {
+		var env envelope
+		_, hasVersion := fields["schemaVersion"]
+		_, hasData := fields["data"]
+		if hasVersion && hasData && json.Unmarshal(contents, &env) == nil {
+			return env.SchemaVersion, env.Data
+		}
+	}
+
+	return 0, contents
+}
+
+func encode(name string, v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(envelope{
+		SchemaVersion: CurrentVersion(name),
+		Data:          data,
+	}, "", "  ")
+}
+
+// migrate upgrades data of the given version to the current version.
+func migrate(name string, version int, data json.RawMessage) (json.RawMessage, error) {
+	steps := migrations[name]
+	if version > len(steps) {
+		return nil, fmt.Errorf("%s: %w %d (newest known is %d)", name, ErrUnsupportedVersion, version, len(steps))
+	}
+
+	for i := version; i < len(steps); i++ {
+		var err error
+		data, err = steps[i](data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: migrate to version %d: %w", name, i+1, err)
+		}
+	}
+
+	return data, nil
+}
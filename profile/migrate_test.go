@@ -0,0 +1,160 @@
+package profile
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name        string
+		contents    string
+		wantVersion int
+		wantData    string
+	}{
+		{"envelope", `{"schemaVersion":1,"data":{"a":1}}`, 1, `{"a":1}`},
+		{"unversioned object", `{"a":1}`, 0, `{"a":1}`},
+		{"unversioned array", `[{"name":"a"}]`, 0, `[{"name":"a"}]`},
+		{"extra key", `{"schemaVersion":1,"data":{},"other":true}`, 0, `{"schemaVersion":1,"data":{},"other":true}`},
+		{"missing data", `{"schemaVersion":1,"other":true}`, 0, `{"schemaVersion":1,"other":true}`},
+		{"version not a number", `{"schemaVersion":"1","data":{}}`, 0, `{"schemaVersion":"1","data":{}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version, data := decode([]byte(tt.contents))
+			if version != tt.wantVersion || string(data) != tt.wantData {
+				t.Errorf("decode() = %d, %s, want %d, %s", version, data, tt.wantVersion, tt.wantData)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		contents string
+		want     string
+		wantErr  error
+		// wantSaved is what the store holds after Load, the contents if empty
+		wantSaved string
+	}{
+		{
+			name:      "unversioned object",
+			document:  Device,
+			contents:  `{"id":"a"}`,
+			want:      `{"id":"a"}`,
+			wantSaved: "{\n  \"schemaVersion\": 1,\n  \"data\": {\n    \"id\": \"a\"\n  }\n}",
+		},
+		{
+			name:      "unversioned array",
+			document:  Cookies,
+			contents:  `[{"name":"a"}]`,
+			want:      `[{"name":"a"}]`,
+			wantSaved: "{\n  \"schemaVersion\": 1,\n  \"data\": [\n    {\n      \"name\": \"a\"\n    }\n  ]\n}",
+		},
+		{
+			name:     "current version",
+			document: Device,
+			contents: `{"schemaVersion":1,"data":{"id":"a"}}`,
+			want:     `{"id":"a"}`,
+		},
+		{
+			name:     "unversioned document without migrations",
+			document: StepUps,
+			contents: `{"a":"b"}`,
+			want:     `{"a":"b"}`,
+		},
+		{
+			name:     "newer version",
+			document: Device,
+			contents: `{"schemaVersion":2,"data":{"id":"a"}}`,
+			wantErr:  ErrUnsupportedVersion,
+		},
+		{
+			name:     "wrong shape",
+			document: Cookies,
+			contents: `{"name":"a"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			store.Write(tt.document, []byte(tt.contents))
+			p := New("test", t.TempDir(), store)
+
+			var got json.RawMessage
+			err := p.Load(tt.document, &got)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Load() error = %v, want %v", err, tt.wantErr)
+				}
+			case tt.want == "":
+				if err == nil {
+					t.Fatalf("Load() = %s, want an error", got)
+				}
+			case err != nil:
+				t.Fatalf("Load() error = %v", err)
+			case string(got) != tt.want:
+				t.Errorf("Load() = %s, want %s", got, tt.want)
+			}
+
+			wantSaved := tt.wantSaved
+			if wantSaved == "" {
+				wantSaved = tt.contents
+			}
+
+			if saved, _ := store.Read(tt.document); string(saved) != wantSaved {
+				t.Errorf("saved = %s, want %s", saved, wantSaved)
+			}
+		})
+	}
+}
+
+func TestPeek(t *testing.T) {
+	store := NewMemoryStore()
+	store.Write(Device, []byte(`{"id":"a"}`))
+	p := New("test", t.TempDir(), store)
+
+	var got struct {
+		ID string `json:"id"`
+	}
+	if err := p.Peek(Device, &got); err != nil {
+		t.Fatalf("Peek() error = %v", err)
+	}
+
+	if got.ID != "a" {
+		t.Errorf("Peek() id = %q, want %q", got.ID, "a")
+	}
+
+	if saved, _ := store.Read(Device); string(saved) != `{"id":"a"}` {
+		t.Errorf("Peek() saved the document: %s", saved)
+	}
+}
+
+func TestSaveRoundTrip(t *testing.T) {
+	p := New("test", t.TempDir(), NewMemoryStore())
+
+	want := []string{"a", "b"}
+	if err := p.Save(Cookies, want); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	contents, _ := p.Store.Read(Cookies)
+	if version, _ := decode(contents); version != CurrentVersion(Cookies) {
+		t.Errorf("saved version = %d, want %d", version, CurrentVersion(Cookies))
+	}
+
+	var got []string
+	if err := p.Load(Cookies, &got); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if len(got) != 2 || got[0] != "a" || got[1] != "b" {
+		t.Errorf("Load() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,167 @@
+package profile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Profile is the saved state of one login. Documents are versioned and
+// migrated to the current schema when loaded. Dir holds what cannot live in a
+// Store, like the browser's user data and card exports.
+type Profile struct {
+	Name  string
+	Dir   string
+	Store Store
+}
+
+// Open returns the profile kept in dir by a FileStore.
+func Open(dir string) (*Profile, error) {
+	store, err := NewFileStore(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return New(filepath.Base(dir), dir, store), nil
+}
+
+func New(name string, dir string, store Store) *Profile {
+	return &Profile{
+		Name:  name,
+		Dir:   dir,
+		Store: store,
+	}
+}
+
+// Load decodes the document name into v, migrating it first if it was saved
+// by an older release. It returns an error wrapping ErrNotFound if the
+// document does not exist.
+func (p *Profile) Load(name string, v any) error {
+	data, migrated, err := p.read(name)
+	if err != nil {
+		return err
+	}
+
+	if migrated {
+		if err := p.Save(name, data); err != nil {
+			return fmt.Errorf("%s: save migrated: %w", name, err)
+		}
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// Peek decodes the document name into v like Load, but only migrates it in
+// memory so the saved document is left untouched.
+func (p *Profile) Peek(name string, v any) error {
+	data, _, err := p.read(name)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// read returns the data of the document name at the current version and
+// whether it had to be migrated.
+func (p *Profile) read(name string) (json.RawMessage, bool, error) {
+	contents, err := p.Store.Read(name)
+	if err != nil {
+		return nil, false, err
+	}
+
+	version, data := decode(contents)
+	if version == CurrentVersion(name) {
+		return data, false, nil
+	}
+
+	data, err = migrate(name, version, data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return data, true, nil
+}
+
+// Save encodes v as the current version of the document name.
+func (p *Profile) Save(name string, v any) error {
+	contents, err := encode(name, v)
+	if err != nil {
+		return err
+	}
+
+	return p.Store.Write(name, contents)
+}
+
+func (p *Profile) Delete(name string) error {
+	return p.Store.Delete(name)
+}
+
+// Exists reports whether the document name is saved.
+func (p *Profile) Exists(name string) (bool, error) {
+	_, err := p.Store.Read(name)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// GetDirectory returns a directory inside the profile, creating it if needed.
+func (p *Profile) GetDirectory(parts ...string) (string, error) {
+	dir := filepath.Join(p.Dir, filepath.Join(parts...))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+
+	return dir, nil
+}
+
+// Resource is a typed document of a Profile, loaded once and cached.
+type Resource[T any] struct {
+	mu       sync.Mutex
+	profile  *Profile
+	name     string
+	data     T
+	isLoaded bool
+}
+
+func NewResource[T any](profile *Profile, name string) *Resource[T] {
+	return &Resource[T]{
+		profile: profile,
+		name:    name,
+	}
+}
+
+func (r *Resource[T]) Set(data T) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.profile.Save(r.name, data); err != nil {
+		return err
+	}
+
+	r.data = data
+	r.isLoaded = true
+	return nil
+}
+
+func (r *Resource[T]) Get() (T, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.isLoaded {
+		var data T
+		if err := r.profile.Load(r.name, &data); err != nil {
+			return data, err
+		}
+
+		r.data = data
+		r.isLoaded = true
+	}
+
+	return r.data, nil
+}
@@ -0,0 +1,79 @@
+// Package profile persists everything eno knows about a login: credentials,
+// the enrolled device, session cookies, express enrollment and step-up
+// authorizations.
+//
+// Documents are kept in a Store. FileStore is the default, MemoryStore is
+// meant for tests and EncryptedStore encrypts another Store with a
+// passphrase. Profile adds schema versioning on top so documents written by
+// older releases are migrated when they are loaded.
+package profile
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"sync"
+)
+
+var (
+	ErrNotFound = errors.New("document not found")
+)
+
+// Store keeps named documents. Implementations must be safe for concurrent
+// use.
+type Store interface {
+	// Read returns the document name, or an error wrapping ErrNotFound.
+	Read(name string) ([]byte, error)
+	// Write replaces the document name.
+	Write(name string, data []byte) error
+	// Delete removes the document name. Deleting a missing document is not
+	// an error.
+	Delete(name string) error
+	// List returns the names of every document, sorted.
+	List() ([]string, error)
+}
+
+// MemoryStore keeps documents in memory.
+type MemoryStore struct {
+	mu   sync.Mutex
+	docs map[string][]byte
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{docs: map[string][]byte{}}
+}
+
+func (s *MemoryStore) Read(name string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.docs[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return slices.Clone(data), nil
+}
+
+func (s *MemoryStore) Write(name string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.docs[name] = slices.Clone(data)
+	return nil
+}
+
+func (s *MemoryStore) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.docs, name)
+	return nil
+}
+
+func (s *MemoryStore) List() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Sorted(maps.Keys(s.docs)), nil
+}
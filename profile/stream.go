@@ -0,0 +1,164 @@
+package profile
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// chunkSize is how much plaintext is sealed at once in a stream, so bundles
+// are encrypted and decrypted without holding them in memory.
+const chunkSize = 64 * 1024
+
+// chunkNonce derives the nonce of chunk i from the stream's base nonce.
+func chunkNonce(base []byte, i uint64) []byte {
+	nonce := make([]byte, len(base))
+	copy(nonce, base)
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], i)
+	for j := range counter {
+		nonce[len(nonce)-8+j] ^= counter[j]
+	}
+
+	return nonce
+}
+
+// chunkAAD binds a chunk to the stream's additional data and marks the last
+// chunk, so a stream cut at a chunk boundary does not authenticate.
+func chunkAAD(additionalData []byte, last bool) []byte {
+	aad := append([]byte{}, additionalData...)
+	if last {
+		return append(aad, 1)
+	}
+
+	return append(aad, 0)
+}
+
+// sealWriter seals everything written to it in chunks of chunkSize. Close
+// seals the last chunk, which may be empty.
+type sealWriter struct {
+	w              io.Writer
+	aead           cipher.AEAD
+	nonce          []byte
+	additionalData []byte
+
+	buf   []byte
+	chunk uint64
+}
+
+func newSealWriter(w io.Writer, aead cipher.AEAD, nonce []byte, additionalData []byte) *sealWriter {
+	return &sealWriter{
+		w:              w,
+		aead:           aead,
+		nonce:          nonce,
+		additionalData: additionalData,
+		buf:            make([]byte, 0, chunkSize),
+	}
+}
+
+func (s *sealWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		// A full chunk is only sealed once more data follows, as the last
+		// chunk is sealed differently
+		if len(s.buf) == chunkSize {
+			if err := s.flush(false); err != nil {
+				return written, err
+			}
+		}
+
+		n := copy(s.buf[len(s.buf):chunkSize], p)
+		s.buf = s.buf[:len(s.buf)+n]
+		p = p[n:]
+		written += n
+	}
+
+	return written, nil
+}
+
+func (s *sealWriter) flush(last bool) error {
+	sealed := s.aead.Seal(nil, chunkNonce(s.nonce, s.chunk), s.buf, chunkAAD(s.additionalData, last))
+	if _, err := s.w.Write(sealed); err != nil {
+		return err
+	}
+
+	s.buf = s.buf[:0]
+	s.chunk++
+	return nil
+}
+
+func (s *sealWriter) Close() error {
+	return s.flush(true)
+}
+
+// openReader reads a stream written by sealWriter. Chunks are authenticated
+// before they are returned, the first one failing with ErrWrongPassphrase.
+type openReader struct {
+	r              *bufio.Reader
+	aead           cipher.AEAD
+	nonce          []byte
+	additionalData []byte
+
+	buf   []byte
+	chunk uint64
+	done  bool
+}
+
+func newOpenReader(r io.Reader, aead cipher.AEAD, nonce []byte, additionalData []byte) *openReader {
+	return &openReader{
+		r:              bufio.NewReaderSize(r, chunkSize+aead.Overhead()),
+		aead:           aead,
+		nonce:          nonce,
+		additionalData: additionalData,
+	}
+}
+
+func (o *openReader) Read(p []byte) (int, error) {
+	for len(o.buf) == 0 {
+		if o.done {
+			return 0, io.EOF
+		}
+
+		if err := o.next(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, o.buf)
+	o.buf = o.buf[n:]
+	return n, nil
+}
+
+func (o *openReader) next() error {
+	sealed := make([]byte, chunkSize+o.aead.Overhead())
+	n, err := io.ReadFull(o.r, sealed)
+	switch {
+	case errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF):
+		o.done = true
+	case err != nil:
+		return err
+	default:
+		if _, err := o.r.Peek(1); errors.Is(err, io.EOF) {
+			o.done = true
+		} else if err != nil {
+			return err
+		}
+	}
+
+	plaintext, err := o.aead.Open(sealed[:0], chunkNonce(o.nonce, o.chunk), sealed[:n], chunkAAD(o.additionalData, o.done))
+	if err != nil {
+		if o.chunk == 0 {
+			return fmt.Errorf("%w or corrupted bundle", ErrWrongPassphrase)
+		}
+
+		return fmt.Errorf("%w: corrupted or truncated", ErrInvalidBundle)
+	}
+
+	o.buf = plaintext
+	o.chunk++
+	return nil
+}
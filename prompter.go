@@ -0,0 +1,65 @@
+package eno
+
+import (
+	"context"
+	"errors"
+
+	"github.com/saucesteals/eno/extension"
+	"github.com/saucesteals/eno/otp"
+)
+
+var (
+	ErrNoPrompter = errors.New("no prompter configured")
+)
+
+// Prompter supplies the input a Client needs from the user while logging in.
+// OTP codes are read through the embedded otp.Provider.
+type Prompter interface {
+	otp.Provider
+	// SelectDestination picks where OTP codes are sent.
+	SelectDestination(ctx context.Context, destinations []otp.Destination) (int, error)
+	// CVV returns the CVV of card, needed to register it with a new device.
+	CVV(ctx context.Context, card extension.PaymentCard) (string, error)
+}
+
+// Callbacks adapts functions to a Prompter. Select defaults to the first
+// destination.
+type Callbacks struct {
+	OTP    otp.Provider
+	Select otp.Selector
+	GetCVV func(ctx context.Context, card extension.PaymentCard) (string, error)
+}
+
+var _ Prompter = Callbacks{}
+
+func (c Callbacks) Code(ctx context.Context, req otp.Request) (string, error) {
+	if c.OTP == nil {
+		return "", ErrNoPrompter
+	}
+
+	return c.OTP.Code(ctx, req)
+}
+
+func (c Callbacks) Policy() otp.Policy {
+	if c.OTP == nil {
+		return otp.Policy{}
+	}
+
+	return c.OTP.Policy()
+}
+
+func (c Callbacks) SelectDestination(ctx context.Context, destinations []otp.Destination) (int, error) {
+	if c.Select == nil {
+		return 0, nil
+	}
+
+	return c.Select(ctx, destinations)
+}
+
+func (c Callbacks) CVV(ctx context.Context, card extension.PaymentCard) (string, error) {
+	if c.GetCVV == nil {
+		return "", ErrNoPrompter
+	}
+
+	return c.GetCVV(ctx, card)
+}
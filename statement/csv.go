@@ -0,0 +1,105 @@
+package statement
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var csvDateLayouts = []string{"2006-01-02", "01/02/2006", "1/2/2006", "01/02/06"}
+
+// ParseCSV parses a Capital One CSV export with the columns Transaction Date,
+// Posted Date, Card No., Description, Category, Debit and Credit. A single
+// Amount column, negative for charges, is also accepted.
+func ParseCSV(r io.Reader) ([]Transaction, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		columns[name] = i
+	}
+
+	column := func(row []string, names ...string) string {
+		for _, name := range names {
+			if i, ok := columns[name]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+		}
+		return ""
+	}
+
+	amount := func(value string) (float64, error) {
+		value = strings.NewReplacer("$", "", ",", "").Replace(value)
+		if value == "" {
+			return 0, nil
+		}
+		return strconv.ParseFloat(value, 64)
+	}
+
+	transactions := []Transaction{}
+	for line := 2; ; line++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		date, err := parseDate(column(row, "transaction date", "date"), csvDateLayouts...)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		transaction := Transaction{
+			Date:        date,
+			LastFour:    column(row, "card no.", "card no", "card number", "card"),
+			Description: column(row, "description", "merchant"),
+			Category:    column(row, "category"),
+		}
+
+		if posted := column(row, "posted date"); posted != "" {
+			if transaction.PostedDate, err = parseDate(posted, csvDateLayouts...); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+
+		if _, ok := columns["amount"]; ok {
+			value, err := amount(column(row, "amount"))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid amount: %w", line, err)
+			}
+			transaction.Amount = -value
+		} else {
+			debit, err := amount(column(row, "debit"))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid debit: %w", line, err)
+			}
+
+			credit, err := amount(column(row, "credit"))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid credit: %w", line, err)
+			}
+
+			transaction.Amount = debit - credit
+		}
+
+		if len(transaction.LastFour) > 4 {
+			transaction.LastFour = transaction.LastFour[len(transaction.LastFour)-4:]
+		}
+
+		transactions = append(transactions, transaction)
+	}
+
+	return transactions, nil
+}
@@ -0,0 +1,78 @@
+package statement
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	ofxTransactionPattern = regexp.MustCompile(`(?is)<STMTTRN>(.*?)</STMTTRN>`)
+	ofxFieldPattern       = regexp.MustCompile(`(?i)<([A-Z0-9.]+)>([^<\r\n]*)`)
+	// ofxLastFourPattern finds the card's last four digits in a memo such as
+	// "CARD ENDING IN 1234" or a masked number like "XXXX-XXXX-XXXX-1234",
+	// "****1234" or "...1234". Digits followed by a date separator, as in
+	// "ending 2024-05-01", are not a card number.
+	ofxLastFourPattern = regexp.MustCompile(`(?i)(?:\bending(?:\s+in)?\s*:?\s*|(?:[x*]{2,}[-\s]?)+|\.{3}\s?)(\d{4})(?:$|[^\d/-])`)
+)
+
+// ParseOFX parses the transactions of an OFX or QFX export, in either the SGML
+// or XML flavour. OFX has no card number per transaction, so LastFour is only
+// set when the memo mentions it.
+func ParseOFX(r io.Reader) ([]Transaction, error) {
+	contents, err := readAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	transactions := []Transaction{}
+	for _, match := range ofxTransactionPattern.FindAllStringSubmatch(contents, -1) {
+		fields := map[string]string{}
+		for _, field := range ofxFieldPattern.FindAllStringSubmatch(match[1], -1) {
+			fields[strings.ToUpper(field[1])] = strings.TrimSpace(field[2])
+		}
+
+		posted := fields["DTPOSTED"]
+		if len(posted) < 8 {
+			return nil, fmt.Errorf("transaction %s: invalid date: %s", fields["FITID"], posted)
+		}
+
+		date, err := parseDate(posted[:8], "20060102")
+		if err != nil {
+			return nil, fmt.Errorf("transaction %s: %w", fields["FITID"], err)
+		}
+
+		amount, err := strconv.ParseFloat(fields["TRNAMT"], 64)
+		if err != nil {
+			return nil, fmt.Errorf("transaction %s: invalid amount: %w", fields["FITID"], err)
+		}
+
+		transaction := Transaction{
+			ID:          fields["FITID"],
+			Date:        date,
+			PostedDate:  date,
+			Description: fields["NAME"],
+			Amount:      -amount,
+		}
+
+		if user, ok := fields["DTUSER"]; ok && len(user) >= 8 {
+			if t, err := parseDate(user[:8], "20060102"); err == nil {
+				transaction.Date = t
+			}
+		}
+
+		if m := ofxLastFourPattern.FindStringSubmatch(fields["MEMO"]); m != nil {
+			transaction.LastFour = m[1]
+		}
+
+		if transaction.Description == "" {
+			transaction.Description = fields["MEMO"]
+		}
+
+		transactions = append(transactions, transaction)
+	}
+
+	return transactions, nil
+}
@@ -0,0 +1,164 @@
+package statement
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/saucesteals/eno/inventory"
+)
+
+var (
+	// cardNameSuffix strips the " Card 3" eno appends to created cards
+	cardNameSuffix  = regexp.MustCompile(`(?i)\s*\bcard(\s*\d+)?$`)
+	nonAlphanumeric = regexp.MustCompile(`[^a-z0-9]+`)
+
+	// genericNames are card name prefixes that say nothing about the merchant
+	genericNames = []string{"", "web", "renewed"}
+)
+
+// Alert flags a charge from a merchant other than the one a card is bound to
+// or named after.
+type Alert struct {
+	Transaction Transaction `json:"transaction"`
+	Expected    string      `json:"expected"`
+}
+
+type CardSpend struct {
+	Record       inventory.Record `json:"record"`
+	Transactions []Transaction    `json:"transactions"`
+	Total        float64          `json:"total"`
+	Alerts       []Alert          `json:"alerts"`
+}
+
+type Report struct {
+	Cards []CardSpend `json:"cards"`
+	// Unmatched are transactions no virtual card could be found for
+	Unmatched []Transaction `json:"unmatched"`
+	Total     float64       `json:"total"`
+}
+
+// ExpectedMerchant is the merchant a card should be charged by: its bound
+// merchant, or else the merchant it is named after.
+func ExpectedMerchant(record inventory.Record) string {
+	if record.MerchantName != "" {
+		return record.MerchantName
+	}
+
+	name := strings.TrimSpace(cardNameSuffix.ReplaceAllString(record.TokenName, ""))
+	if slices.Contains(genericNames, strings.ToLower(name)) {
+		return ""
+	}
+
+	return name
+}
+
+// MerchantMatches reports whether a statement description plausibly belongs
+// to merchant, by looking for the first significant word of its name.
+func MerchantMatches(merchant string, description string) bool {
+	normalized := nonAlphanumeric.ReplaceAllString(strings.ToLower(description), "")
+
+	for _, word := range nonAlphanumeric.Split(strings.ToLower(merchant), -1) {
+		if len(word) < 3 || word == "www" || word == "the" {
+			continue
+		}
+
+		return strings.Contains(normalized, word)
+	}
+
+	return true
+}
+
+// match picks the record a transaction most likely belongs to among the cards
+// ending in its last four digits.
+func match(records []inventory.Record, transaction Transaction) (inventory.Record, bool) {
+	candidates := []inventory.Record{}
+	for _, record := range records {
+		if transaction.LastFour != "" && record.TokenLastFour == transaction.LastFour {
+			candidates = append(candidates, record)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return inventory.Record{}, false
+	}
+
+	// Cards created after the charge cannot have made it, allowing a day for
+	// time zones
+	existing := slices.DeleteFunc(slices.Clone(candidates), func(record inventory.Record) bool {
+		return record.CreatedAt.After(transaction.Date.Add(time.Hour * 24))
+	})
+	if len(existing) > 0 {
+		candidates = existing
+	}
+
+	slices.SortStableFunc(candidates, func(a, b inventory.Record) int {
+		aMatches := MerchantMatches(ExpectedMerchant(a), transaction.Description)
+		bMatches := MerchantMatches(ExpectedMerchant(b), transaction.Description)
+		if aMatches != bMatches {
+			if aMatches {
+				return -1
+			}
+			return 1
+		}
+
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+
+	return candidates[0], true
+}
+
+// Join attributes transactions to records and totals the spend of each card.
+func Join(records []inventory.Record, transactions []Transaction) Report {
+	report := Report{
+		Cards:     []CardSpend{},
+		Unmatched: []Transaction{},
+	}
+
+	byToken := map[string]*CardSpend{}
+	order := []string{}
+	for _, transaction := range transactions {
+		record, ok := match(records, transaction)
+		if !ok {
+			report.Unmatched = append(report.Unmatched, transaction)
+			continue
+		}
+
+		spend, ok := byToken[record.TokenReferenceID]
+		if !ok {
+			spend = &CardSpend{
+				Record:       record,
+				Transactions: []Transaction{},
+				Alerts:       []Alert{},
+			}
+			byToken[record.TokenReferenceID] = spend
+			order = append(order, record.TokenReferenceID)
+		}
+
+		spend.Transactions = append(spend.Transactions, transaction)
+		spend.Total += transaction.Amount
+		report.Total += transaction.Amount
+
+		expected := ExpectedMerchant(record)
+		if transaction.Amount > 0 && expected != "" && !MerchantMatches(expected, transaction.Description) {
+			spend.Alerts = append(spend.Alerts, Alert{Transaction: transaction, Expected: expected})
+		}
+	}
+
+	for _, id := range order {
+		report.Cards = append(report.Cards, *byToken[id])
+	}
+
+	slices.SortStableFunc(report.Cards, func(a, b CardSpend) int {
+		switch {
+		case a.Total > b.Total:
+			return -1
+		case a.Total < b.Total:
+			return 1
+		}
+		return 0
+	})
+
+	return report
+}
@@ -0,0 +1,31 @@
+package statement
+
+import (
+	"testing"
+
+	"github.com/saucesteals/eno/inventory"
+)
+
+func TestExpectedMerchant(t *testing.T) {
+	tests := []struct {
+		name   string
+		record inventory.Record
+		want   string
+	}{
+		{"bound merchant", inventory.Record{TokenName: "Web Card 3", MerchantName: "Netflix"}, "Netflix"},
+		{"numbered card", inventory.Record{TokenName: "Netflix Card 3"}, "Netflix"},
+		{"card suffix", inventory.Record{TokenName: "netflix card"}, "netflix"},
+		{"generic name", inventory.Record{TokenName: "Web Card 12"}, ""},
+		{"only card", inventory.Record{TokenName: "Card"}, ""},
+		{"word ending in card", inventory.Record{TokenName: "Discard"}, "Discard"},
+		{"merchant ending in card", inventory.Record{TokenName: "Mastercard"}, "Mastercard"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExpectedMerchant(tt.record); got != tt.want {
+				t.Errorf("ExpectedMerchant(%q) = %q, want %q", tt.record.TokenName, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+// Package statement parses transaction exports downloaded from Capital One and
+// attributes each transaction to a virtual card from the local inventory.
+package statement
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Transaction is a single line of a statement export. Amount is positive for
+// charges and negative for credits.
+type Transaction struct {
+	ID          string    `json:"id,omitempty"`
+	Date        time.Time `json:"date"`
+	PostedDate  time.Time `json:"postedDate,omitzero"`
+	LastFour    string    `json:"lastFour"`
+	Description string    `json:"description"`
+	Category    string    `json:"category,omitempty"`
+	Amount      float64   `json:"amount"`
+}
+
+// ParseFile parses a CSV or OFX/QFX export, chosen by the file extension.
+func ParseFile(path string) ([]Transaction, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var transactions []Transaction
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".csv":
+		transactions, err = ParseCSV(f)
+	case ".ofx", ".qfx":
+		transactions, err = ParseOFX(f)
+	default:
+		return nil, fmt.Errorf("unsupported statement format: %s", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return transactions, nil
+}
+
+func parseDate(value string, layouts ...string) (time.Time, error) {
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid date: %s", value)
+}
+
+func readAll(r io.Reader) (string, error) {
+	contents, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+
+	return string(contents), nil
+}
@@ -0,0 +1,28 @@
+package eno
+
+import (
+	"github.com/saucesteals/eno/profile"
+)
+
+var (
+	ErrNotFound = profile.ErrNotFound
+)
+
+// Names of the documents a Client keeps in its Storage.
+const (
+	StorageDevice  = profile.Device
+	StorageCookies = profile.Cookies
+	StorageExpress = profile.Express
+	StorageStepUps = profile.StepUps
+)
+
+// Storage persists the state of a profile as named JSON documents. It is
+// implemented by *profile.Profile.
+type Storage interface {
+	// Load decodes the document name into v, or returns an error wrapping
+	// ErrNotFound if it does not exist.
+	Load(name string, v any) error
+	Save(name string, v any) error
+}
+
+var _ Storage = (*profile.Profile)(nil)
@@ -8,49 +8,66 @@ import (
 	"fmt"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/go-jose/go-jose/v3"
 	"github.com/saucesteals/eno/extension"
+	"github.com/saucesteals/eno/otp"
 	http "github.com/saucesteals/fhttp"
 
 	"github.com/google/uuid"
 )
 
 var (
+	ErrChallengeRedirect    = errors.New("challenge redirected")
+	ErrUnsupportedChallenge = errors.New("unsupported challenge")
+
 	verificationBusinessEvent = "CARD.SERVICING.WEB.EASE.VIRTUAL_CARD_VCNCREATE"
 )
 
-func (a *Web) ChallengeValidation(ctx context.Context, policyProcessID string, otp ChallengeVerificationOtp, otpValue string) error {
+// ChallengeValidation submits otpValue. A wrong code returns an error wrapping
+// otp.ErrRejected alongside the remaining attempts.
+func (a *Web) ChallengeValidation(ctx context.Context, policyProcessID string, verification ChallengeVerificationOtp, otpValue string) (otp.Result, error) {
+	result, _, err := a.challengeValidation(ctx, policyProcessID, verification, otpValue)
+	return result, err
+}
+
+// challengeValidation is ChallengeValidation that also returns when the
+// cookies set by an accepted code expire, or the zero time if none of them
+// carry an expiry.
+func (a *Web) challengeValidation(ctx context.Context, policyProcessID string, verification ChallengeVerificationOtp, otpValue string) (otp.Result, time.Time, error) {
 	type Payload struct {
-		BusinessEvent                 string `json:"businessEvent"`
-		ChallengeMethod               string `json:"challengeMethod"`
-		PolicyProcessID               string `json:"policyProcessId"`
-		PassphraseAuthenticationToken string `json:"passphraseAuthenticationToken"`
-		EncryptedPassphrase           string `json:"encryptedPassphrase"`
+		BusinessEvent                 string        `json:"businessEvent"`
+		ChallengeMethod               Authenticator `json:"challengeMethod"`
+		PolicyProcessID               string        `json:"policyProcessId"`
+		PassphraseAuthenticationToken string        `json:"passphraseAuthenticationToken"`
+		EncryptedPassphrase           string        `json:"encryptedPassphrase"`
 	}
 
 	type Response struct {
 		Authenticator string `json:"authenticator"`
 		Otp           struct {
-			AcceptanceStatus  string `json:"acceptanceStatus"`
-			ProfileStatus     string `json:"profileStatus"`
-			RemainingAttempts int    `json:"remainingAttempts"`
+			AcceptanceStatus  string            `json:"acceptanceStatus"`
+			ProfileStatus     otp.ProfileStatus `json:"profileStatus"`
+			RemainingAttempts int               `json:"remainingAttempts"`
 		} `json:"otp"`
 		RedirectUrl string `json:"redirectUrl"`
 	}
 
-	decoded, err := base64.StdEncoding.DecodeString(otp.EncryptionKey)
+	result := otp.Result{RemainingAttempts: -1}
+
+	decoded, err := base64.StdEncoding.DecodeString(verification.EncryptionKey)
 	if err != nil {
-		return err
+		return result, time.Time{}, err
 	}
 
 	var keys jose.JSONWebKeySet
 	if err := json.Unmarshal(decoded, &keys); err != nil {
-		return err
+		return result, time.Time{}, err
 	}
 
 	if len(keys.Keys) == 0 {
-		return errors.New("no keys found")
+		return result, time.Time{}, errors.New("no keys found")
 	}
 
 	encrypter, err := jose.NewEncrypter(jose.A128GCM, jose.Recipient{
@@ -58,46 +75,76 @@ func (a *Web) ChallengeValidation(ctx context.Context, policyProcessID string, o
 		Key:       &keys.Keys[0],
 	}, nil)
 	if err != nil {
-		return err
+		return result, time.Time{}, err
 	}
 
 	jwe, err := encrypter.Encrypt([]byte(otpValue))
 	if err != nil {
-		return err
+		return result, time.Time{}, err
 	}
 
 	encrypted, err := jwe.CompactSerialize()
 	if err != nil {
-		return err
+		return result, time.Time{}, err
 	}
 
 	payload := Payload{
 		BusinessEvent:                 verificationBusinessEvent,
-		ChallengeMethod:               "OTP",
+		ChallengeMethod:               AuthenticatorOTP,
 		PolicyProcessID:               policyProcessID,
-		PassphraseAuthenticationToken: otp.AuthenticationToken,
+		PassphraseAuthenticationToken: verification.AuthenticationToken,
 		EncryptedPassphrase:           encrypted,
 	}
 
 	var response Response
 	req, err := a.newVerifiedRequest(ctx, http.MethodPost, "stoic/validation", payload)
 	if err != nil {
-		return err
+		return result, time.Time{}, err
 	}
 
-	if err := a.do(req, &response, nil); err != nil {
-		return err
+	res, err := a.send(req, &response, nil)
+	if err != nil {
+		return result, time.Time{}, err
 	}
 
-	if response.Otp.AcceptanceStatus != "ACCEPTED" {
-		return fmt.Errorf("otp not accepted: %s", response.Otp.AcceptanceStatus)
+	result = otp.Result{
+		Accepted:          response.Otp.AcceptanceStatus == "ACCEPTED",
+		ProfileStatus:     response.Otp.ProfileStatus,
+		RemainingAttempts: response.Otp.RemainingAttempts,
 	}
 
-	if response.Otp.ProfileStatus != "UNLOCKED" {
-		return fmt.Errorf("otp accepted but profile not unlocked: %s", response.Otp.ProfileStatus)
+	if !result.Accepted {
+		return result, time.Time{}, fmt.Errorf("%w: %s", otp.ErrRejected, response.Otp.AcceptanceStatus)
 	}
 
-	return nil
+	if result.ProfileStatus != otp.ProfileStatusUnlocked {
+		return result, time.Time{}, fmt.Errorf("otp accepted but profile not unlocked: %s", response.Otp.ProfileStatus)
+	}
+
+	return result, cookiesExpireAt(res.Cookies()), nil
+}
+
+// cookiesExpireAt returns the earliest expiry of cookies, or the zero time if
+// none of them carry one.
+func cookiesExpireAt(cookies []*http.Cookie) time.Time {
+	var expiresAt time.Time
+	for _, cookie := range cookies {
+		var at time.Time
+		switch {
+		case cookie.MaxAge > 0:
+			at = time.Now().Add(time.Duration(cookie.MaxAge) * time.Second)
+		case cookie.MaxAge == 0 && !cookie.Expires.IsZero():
+			at = cookie.Expires
+		default:
+			continue
+		}
+
+		if expiresAt.IsZero() || at.Before(expiresAt) {
+			expiresAt = at
+		}
+	}
+
+	return expiresAt
 }
 
 type ChallengeVerificationOtp struct {
@@ -106,33 +153,37 @@ type ChallengeVerificationOtp struct {
 }
 
 type ChallengeVerificationResponse struct {
-	Authenticator   string                   `json:"authenticator"`
+	Authenticator   Authenticator            `json:"authenticator"`
 	Otp             ChallengeVerificationOtp `json:"otp"`
 	PolicyProcessID string                   `json:"policyProcessId"`
 }
 
-func (a *Web) ChallengeVerification(ctx context.Context, policyProcessID string, contactPoint ChallengeContactPoint) (ChallengeVerificationResponse, error) {
+func (a *Web) ChallengeVerification(ctx context.Context, policyProcessID string, option ChallengeOption) (ChallengeVerificationResponse, error) {
 	type SelectedContactPoint struct {
-		ID             string `json:"id"`
-		DeliveryMedium string `json:"deliveryMedium"`
-		MaskedValue    string `json:"maskedValue"`
+		ID             string         `json:"id"`
+		DeliveryMedium DeliveryMedium `json:"deliveryMedium"`
+		MaskedValue    string         `json:"maskedValue"`
 	}
 
 	type Payload struct {
 		BusinessEvent        string               `json:"businessEvent"`
-		ChallengeMethod      string               `json:"challengeMethod"`
+		ChallengeMethod      Authenticator        `json:"challengeMethod"`
 		PolicyProcessID      string               `json:"policyProcessId"`
 		SelectedContactPoint SelectedContactPoint `json:"selectedContactPoint"`
 	}
 
+	if !option.Supported() {
+		return ChallengeVerificationResponse{}, fmt.Errorf("%w: %s", ErrUnsupportedChallenge, option)
+	}
+
 	payload := Payload{
 		BusinessEvent:   verificationBusinessEvent,
-		ChallengeMethod: "OTP",
+		ChallengeMethod: option.Authenticator,
 		PolicyProcessID: policyProcessID,
 		SelectedContactPoint: SelectedContactPoint{
-			ID:             contactPoint.ContactPointID,
-			DeliveryMedium: "SMS",
-			MaskedValue:    contactPoint.ContactPointMasked,
+			ID:             option.ContactPoint.ContactPointID,
+			DeliveryMedium: option.Medium,
+			MaskedValue:    option.ContactPoint.ContactPointMasked,
 		},
 	}
 
@@ -150,8 +201,46 @@ func (a *Web) ChallengeVerification(ctx context.Context, policyProcessID string,
 
 }
 
+type DeliveryMedium string
+
+var (
+	DeliveryMediumSMS   DeliveryMedium = "SMS"
+	DeliveryMediumEmail DeliveryMedium = "EMAIL"
+	DeliveryMediumVoice DeliveryMedium = "VOICE"
+	DeliveryMediumPush  DeliveryMedium = "PUSH"
+)
+
+type Authenticator string
+
+var (
+	AuthenticatorOTP  Authenticator = "OTP"
+	AuthenticatorPush Authenticator = "PUSH"
+)
+
 type ChallengeContactPointDeliveryMediums struct {
-	IsSms bool `json:"isSms"`
+	IsSms   bool `json:"isSms"`
+	IsEmail bool `json:"isEmail"`
+	IsVoice bool `json:"isVoice"`
+	IsPush  bool `json:"isPush"`
+}
+
+// Mediums lists every medium a code can be delivered to the contact point with.
+func (m ChallengeContactPointDeliveryMediums) Mediums() []DeliveryMedium {
+	mediums := []DeliveryMedium{}
+	if m.IsSms {
+		mediums = append(mediums, DeliveryMediumSMS)
+	}
+	if m.IsEmail {
+		mediums = append(mediums, DeliveryMediumEmail)
+	}
+	if m.IsVoice {
+		mediums = append(mediums, DeliveryMediumVoice)
+	}
+	if m.IsPush {
+		mediums = append(mediums, DeliveryMediumPush)
+	}
+
+	return mediums
 }
 
 type ChallengeContactPoint struct {
@@ -166,17 +255,97 @@ type ChallengeMethodPayload struct {
 }
 
 type ChallengeMethod struct {
-	Authenticator           string                 `json:"authenticator"`
+	Authenticator           Authenticator          `json:"authenticator"`
 	AvailableMethodsPayload ChallengeMethodPayload `json:"availableMethodsPayload"`
 	IsLegacy                bool                   `json:"isLegacy"`
 }
 
+// ChallengeOption is a single way of completing a step-up challenge: an
+// authenticator delivered to a contact point over a medium.
+type ChallengeOption struct {
+	Authenticator Authenticator
+	ContactPoint  ChallengeContactPoint
+	Medium        DeliveryMedium
+}
+
+// Supported reports whether the option can be completed with a code. Push
+// approvals, other authenticators and methods without a contact point to
+// deliver the code to are listed but cannot be completed by this client.
+func (o ChallengeOption) Supported() bool {
+	return o.Authenticator == AuthenticatorOTP && o.Medium != "" && o.Medium != DeliveryMediumPush
+}
+
+func (o ChallengeOption) String() string {
+	destination := o.ContactPoint.ContactPointMasked
+	if destination == "" {
+		destination = o.ContactPoint.ContactPointLabel
+	}
+
+	switch o.Medium {
+	case DeliveryMediumSMS:
+		return "Text message to " + destination
+	case DeliveryMediumEmail:
+		return "Email to " + destination
+	case DeliveryMediumVoice:
+		return "Voice call to " + destination
+	case DeliveryMediumPush:
+		return "Push notification to " + destination
+	default:
+		return fmt.Sprintf("%s (%s) to %s", o.Authenticator, o.Medium, destination)
+	}
+}
+
 type ChallengeAssessment struct {
 	RedirectURL      string            `json:"redirectUrl"`
 	AvailableMethods []ChallengeMethod `json:"availableMethods"`
 	PolicyProcessID  string            `json:"policyProcessId"`
 }
 
+// Options lists every authenticator, contact point and medium combination the
+// assessment offers, including ones that are not Supported.
+func (c ChallengeAssessment) Options() []ChallengeOption {
+	options := []ChallengeOption{}
+	for _, method := range c.AvailableMethods {
+		contactPoints := method.AvailableMethodsPayload.ContactPoints
+		if len(contactPoints) == 0 {
+			options = append(options, ChallengeOption{Authenticator: method.Authenticator})
+			continue
+		}
+
+		for _, contactPoint := range contactPoints {
+			for _, medium := range contactPoint.ContactPointDeliveryMediums.Mediums() {
+				options = append(options, ChallengeOption{
+					Authenticator: method.Authenticator,
+					ContactPoint:  contactPoint,
+					Medium:        medium,
+				})
+			}
+		}
+	}
+
+	return options
+}
+
+// Authorized reports whether the assessment skipped the challenge and redirected
+// straight back to the virtual card manager. Redirects anywhere else (e.g. to
+// sign in again) are returned as ErrChallengeRedirect.
+func (c ChallengeAssessment) Authorized() (bool, error) {
+	if c.RedirectURL == "" {
+		return false, nil
+	}
+
+	u, err := url.Parse(c.RedirectURL)
+	if err != nil {
+		return false, fmt.Errorf("%w: %w", ErrChallengeRedirect, err)
+	}
+
+	if u.Host != "" && u.Host != "myaccounts.capitalone.com" {
+		return false, fmt.Errorf("%w: %s", ErrChallengeRedirect, c.RedirectURL)
+	}
+
+	return true, nil
+}
+
 func (a *Web) ChallengeAssessment(ctx context.Context, card extension.PaymentCard) (ChallengeAssessment, error) {
 	type WebRiskAssessment struct {
 		DeviceFingerPrint string `json:"deviceFingerPrint"`
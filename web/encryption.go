@@ -30,6 +30,7 @@ func (a *Web) GetGWLiteKey(ctx context.Context, productId ProductId) (*jose.JSON
 	if err != nil {
 		return nil, err
 	}
+	defer res.Body.Close()
 
 	var response jose.JSONWebKeySet
 	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
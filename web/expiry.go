@@ -0,0 +1,60 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/saucesteals/eno/api"
+	"github.com/saucesteals/eno/extension"
+)
+
+// ParseExpirationDate parses an MM/YY or MM/YYYY expiration date into the
+// first instant the card is no longer valid, the start of the following month.
+func ParseExpirationDate(date string) (time.Time, error) {
+	month, year, ok := strings.Cut(date, "/")
+	if !ok {
+		return time.Time{}, fmt.Errorf("invalid expiration date: %s", date)
+	}
+
+	m, err := strconv.Atoi(month)
+	if err != nil || m < 1 || m > 12 {
+		return time.Time{}, fmt.Errorf("invalid expiration month: %s", date)
+	}
+
+	y, err := strconv.Atoi(year)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid expiration year: %s", date)
+	}
+
+	if y < 100 {
+		y += 2000
+	}
+
+	return time.Date(y, time.Month(m)+1, 1, 0, 0, 0, 0, time.Local), nil
+}
+
+// ExpiresAt returns when the card stops being valid according to its
+// expiration date. Cards with a duration may stop earlier, see Expired.
+func (t ListedToken) ExpiresAt() (time.Time, error) {
+	return ParseExpirationDate(t.FormattedTokenExpirationDate)
+}
+
+// Expired reports whether the card has passed its expiration date or its
+// duration.
+func (t ListedToken) Expired() bool {
+	return t.HasTokenExpired || t.DurationHasPassed
+}
+
+// RenewToken creates a replacement for token with the same name and merchant
+// binding. The old token is left untouched.
+func (a *Web) RenewToken(ctx context.Context, card extension.PaymentCard, token ListedToken) (api.Token, error) {
+	opts := CreateTokenOptions{
+		MdxID:    token.MdxInfo.MdxID,
+		MdxURLID: token.MdxInfo.MdxURLID,
+	}
+
+	return a.CreateToken(ctx, token.TokenName, card, opts)
+}
@@ -0,0 +1,98 @@
+package web
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/saucesteals/eno/api"
+	"github.com/saucesteals/eno/extension"
+)
+
+// Provider creates tokens through the web API. It is the only place web
+// tokens step up: each card is assessed before its first token, and again
+// only once its step-up has expired, so callers should not step up
+// themselves.
+type Provider struct {
+	web    *Web
+	stepUp StepUpOptions
+
+	mu sync.Mutex
+	// assessed holds when the last step-up of each card expires
+	assessed map[string]time.Time
+}
+
+var _ api.TokenProvider = (*Provider)(nil)
+
+// Provider returns a TokenProvider using opts for step-up challenges.
+func (a *Web) Provider(opts StepUpOptions) *Provider {
+	return &Provider{web: a, stepUp: opts, assessed: map[string]time.Time{}}
+}
+
+func (p *Provider) Mode() api.TokenMode {
+	return api.TokenModeWeb
+}
+
+// Capabilities of web tokens: merchants must already be resolved to an MdxID.
+func (p *Provider) Capabilities() api.Capabilities {
+	return api.Capabilities{
+		MerchantBinding: true,
+		OneTimeUse:      true,
+		Duration:        true,
+	}
+}
+
+func (p *Provider) Create(ctx context.Context, req api.TokenRequest) (api.TokenResult, error) {
+	if err := p.Capabilities().Check(req); err != nil {
+		return api.TokenResult{}, err
+	}
+
+	card := extension.PaymentCard{CardReferenceID: req.CardReferenceID}
+	if err := p.ensureStepUp(ctx, card); err != nil {
+		return api.TokenResult{}, err
+	}
+
+	opts := CreateTokenOptions{
+		OneTimeUse: req.OneTimeUse,
+		Duration:   TokenDuration(req.Duration),
+	}
+
+	if req.Merchant != nil {
+		opts.MdxID = req.Merchant.MdxID
+		opts.MdxURLID = req.Merchant.MdxURLID
+	}
+
+	token, err := p.web.CreateToken(ctx, req.Name, card, opts)
+	if err != nil {
+		return api.TokenResult{}, err
+	}
+
+	return api.TokenResult{
+		Token:    token,
+		Mode:     p.Mode(),
+		Merchant: req.Merchant,
+	}, nil
+}
+
+// ensureStepUp steps up card unless its last step-up has not expired. A card
+// the assessment let through is assessed again after StepUpValidity.
+func (p *Provider) ensureStepUp(ctx context.Context, card extension.PaymentCard) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if expiresAt, ok := p.assessed[card.CardReferenceID]; ok && time.Now().Before(expiresAt) {
+		return nil
+	}
+
+	result, err := p.web.StepUp(ctx, card, p.stepUp)
+	if err != nil {
+		return err
+	}
+
+	if result.ExpiresAt.IsZero() {
+		result.ExpiresAt = time.Now().Add(StepUpValidity)
+	}
+
+	p.assessed[card.CardReferenceID] = result.ExpiresAt
+	return nil
+}
@@ -0,0 +1,252 @@
+package web
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+type TokenField string
+
+var (
+	TokenFieldName     TokenField = "TOKEN_NAME"
+	TokenFieldMerchant TokenField = "MERCHANT_NAME"
+	TokenFieldStatus   TokenField = "TOKEN_STATUS"
+	TokenFieldType     TokenField = "TOKEN_TYPE"
+	TokenFieldCreated  TokenField = "TOKEN_CREATED_TIMESTAMP"
+	TokenFieldUpdated  TokenField = "TOKEN_UPDATED_TIMESTAMP"
+)
+
+type SortDirection string
+
+var (
+	SortAscending  SortDirection = "ASC"
+	SortDescending SortDirection = "DESC"
+)
+
+type FilterCriteria struct {
+	Field    TokenField `json:"field"`
+	Operator string     `json:"operator"`
+	Value    string     `json:"value"`
+}
+
+type SortCriteria struct {
+	Field     TokenField    `json:"field"`
+	Direction SortDirection `json:"direction"`
+}
+
+type timeRange struct {
+	from time.Time
+	to   time.Time
+}
+
+func (r *timeRange) contains(t time.Time) bool {
+	if r == nil {
+		return true
+	}
+
+	if !r.from.IsZero() && t.Before(r.from) {
+		return false
+	}
+
+	if !r.to.IsZero() && !t.Before(r.to) {
+		return false
+	}
+
+	return true
+}
+
+// TokenQuery selects and orders the tokens returned by ListTokens and Tokens.
+// Name, status and sort order are sent to the server; the remaining filters
+// are applied to each page as it arrives. The zero value matches every token.
+type TokenQuery struct {
+	name            string
+	namePattern     *regexp.Regexp
+	merchant        string
+	statuses        []TokenStatus
+	derivedStatuses []string
+	tokenTypes      []string
+	lastFour        []string
+	expired         *bool
+	created         *timeRange
+	updated         *timeRange
+	sort            []SortCriteria
+	pageSize        int
+}
+
+func NewTokenQuery() TokenQuery {
+	return TokenQuery{}
+}
+
+// Name matches tokens whose name contains name.
+func (q TokenQuery) Name(name string) TokenQuery {
+	q.name = name
+	return q
+}
+
+// NamePattern matches tokens whose name matches pattern.
+func (q TokenQuery) NamePattern(pattern *regexp.Regexp) TokenQuery {
+	q.namePattern = pattern
+	return q
+}
+
+// Merchant matches tokens bound to a merchant whose name or URL contains
+// merchant, case-insensitively.
+func (q TokenQuery) Merchant(merchant string) TokenQuery {
+	q.merchant = strings.ToLower(merchant)
+	return q
+}
+
+func (q TokenQuery) Status(statuses ...TokenStatus) TokenQuery {
+	q.statuses = append(slices.Clone(q.statuses), statuses...)
+	return q
+}
+
+// DerivedStatus matches tokens whose DerivedStatus is one of statuses,
+// case-insensitively.
+func (q TokenQuery) DerivedStatus(statuses ...string) TokenQuery {
+	q.derivedStatuses = slices.Clone(q.derivedStatuses)
+	for _, status := range statuses {
+		q.derivedStatuses = append(q.derivedStatuses, strings.ToUpper(status))
+	}
+	return q
+}
+
+// LastFour matches tokens ending in any of lastFour.
+func (q TokenQuery) LastFour(lastFour ...string) TokenQuery {
+	q.lastFour = append(slices.Clone(q.lastFour), lastFour...)
+	return q
+}
+
+// Expired matches tokens that have (true) or have not (false) expired, by
+// date or duration as ListedToken.Expired reports.
+func (q TokenQuery) Expired(expired bool) TokenQuery {
+	q.expired = &expired
+	return q
+}
+
+func (q TokenQuery) Type(tokenTypes ...string) TokenQuery {
+	q.tokenTypes = append(slices.Clone(q.tokenTypes), tokenTypes...)
+	return q
+}
+
+// CreatedBetween matches tokens created in [from, to). A zero bound is open.
+func (q TokenQuery) CreatedBetween(from, to time.Time) TokenQuery {
+	q.created = &timeRange{from: from, to: to}
+	return q
+}
+
+// UpdatedBetween matches tokens last updated in [from, to). A zero bound is
+// open.
+func (q TokenQuery) UpdatedBetween(from, to time.Time) TokenQuery {
+	q.updated = &timeRange{from: from, to: to}
+	return q
+}
+
+func (q TokenQuery) SortBy(field TokenField, direction SortDirection) TokenQuery {
+	q.sort = append(slices.Clone(q.sort), SortCriteria{Field: field, Direction: direction})
+	return q
+}
+
+// PageSize sets how many tokens are requested per page, the Web's page size
+// by default.
+func (q TokenQuery) PageSize(size int) TokenQuery {
+	q.pageSize = size
+	return q
+}
+
+func (q TokenQuery) getPageSize(fallback int) int {
+	if q.pageSize <= 0 {
+		return fallback
+	}
+
+	return q.pageSize
+}
+
+func (q TokenQuery) filterCriteria() []FilterCriteria {
+	criteria := []FilterCriteria{}
+	if q.name != "" {
+		criteria = append(criteria, FilterCriteria{
+			Field:    TokenFieldName,
+			Operator: "LIKE",
+			Value:    q.name,
+		})
+	}
+
+	return criteria
+}
+
+func (q TokenQuery) sortCriteria() []SortCriteria {
+	if q.sort == nil {
+		return []SortCriteria{}
+	}
+
+	return q.sort
+}
+
+func (q TokenQuery) tokenStatuses() []TokenStatus {
+	if q.statuses == nil {
+		return []TokenStatus{}
+	}
+
+	return q.statuses
+}
+
+// Matches reports whether token satisfies every filter of the query.
+func (q TokenQuery) Matches(token ListedToken) bool {
+	if q.name != "" && !strings.Contains(strings.ToLower(token.TokenName), strings.ToLower(q.name)) {
+		return false
+	}
+
+	if len(q.statuses) > 0 && !slices.Contains(q.statuses, TokenStatus(token.TokenStatus)) {
+		return false
+	}
+
+	return q.matchesLocal(token)
+}
+
+// matchesLocal checks the filters that are not sent to the server.
+func (q TokenQuery) matchesLocal(token ListedToken) bool {
+	if q.namePattern != nil && !q.namePattern.MatchString(token.TokenName) {
+		return false
+	}
+
+	if q.merchant != "" &&
+		!strings.Contains(strings.ToLower(token.MdxInfo.Name), q.merchant) &&
+		!strings.Contains(strings.ToLower(token.MdxInfo.MerchantURL), q.merchant) {
+		return false
+	}
+
+	if len(q.derivedStatuses) > 0 && !slices.Contains(q.derivedStatuses, strings.ToUpper(token.DerivedStatus)) {
+		return false
+	}
+
+	if len(q.tokenTypes) > 0 && !slices.Contains(q.tokenTypes, token.TokenType) {
+		return false
+	}
+
+	if len(q.lastFour) > 0 && !slices.Contains(q.lastFour, token.TokenLastFour) {
+		return false
+	}
+
+	if q.expired != nil && token.Expired() != *q.expired {
+		return false
+	}
+
+	if q.created != nil {
+		createdAt, err := token.CreatedAt()
+		if err != nil || !q.created.contains(createdAt) {
+			return false
+		}
+	}
+
+	if q.updated != nil {
+		updatedAt, err := token.UpdatedAt()
+		if err != nil || !q.updated.contains(updatedAt) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,243 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	http "github.com/saucesteals/fhttp"
+
+	"github.com/saucesteals/eno/extension"
+	"github.com/saucesteals/eno/otp"
+)
+
+var (
+	// StepUpValidity is how long a completed step-up is reused for when the
+	// validation response sets no cookie with an expiry. It is a guess, not a
+	// documented limit.
+	StepUpValidity = time.Minute * 15
+)
+
+// StepUpAuthorization records a completed step-up and the cookies it produced
+// so it can be reused across runs.
+type StepUpAuthorization struct {
+	CardReferenceID string         `json:"cardReferenceId"`
+	AuthorizedAt    time.Time      `json:"authorizedAt"`
+	ExpiresAt       time.Time      `json:"expiresAt"`
+	Cookies         []*http.Cookie `json:"cookies"`
+}
+
+func (s StepUpAuthorization) Valid() bool {
+	return time.Now().Before(s.ExpiresAt)
+}
+
+type StepUpStatus struct {
+	Required bool
+	// Authorization is the saved authorization that will be reused, if any
+	Authorization *StepUpAuthorization
+	// Options are the ways the challenge can be completed when Required
+	Options []ChallengeOption
+}
+
+// StepUpStatus reports whether creating a virtual card for card will require
+// a step-up challenge.
+func (a *Web) StepUpStatus(ctx context.Context, card extension.PaymentCard) (StepUpStatus, error) {
+	if authorization, ok := a.StepUpAuthorization(card); ok {
+		return StepUpStatus{Authorization: &authorization}, nil
+	}
+
+	assessment, err := a.ChallengeAssessment(ctx, card)
+	if err != nil {
+		return StepUpStatus{}, err
+	}
+
+	authorized, err := assessment.Authorized()
+	if err != nil {
+		return StepUpStatus{}, err
+	}
+
+	if authorized {
+		return StepUpStatus{}, nil
+	}
+
+	return StepUpStatus{Required: true, Options: assessment.Options()}, nil
+}
+
+// StepUpAuthorization returns the saved authorization for card if it is still
+// valid.
+func (a *Web) StepUpAuthorization(card extension.PaymentCard) (StepUpAuthorization, bool) {
+	a.muStepUp.Lock()
+	defer a.muStepUp.Unlock()
+
+	authorization, ok := a.stepUps[card.CardReferenceID]
+	if !ok || !authorization.Valid() {
+		return StepUpAuthorization{}, false
+	}
+
+	return authorization, true
+}
+
+// StepUpAuthorizations returns every saved authorization that is still valid.
+func (a *Web) StepUpAuthorizations() []StepUpAuthorization {
+	a.muStepUp.Lock()
+	defer a.muStepUp.Unlock()
+
+	authorizations := []StepUpAuthorization{}
+	for _, authorization := range a.stepUps {
+		if authorization.Valid() {
+			authorizations = append(authorizations, authorization)
+		}
+	}
+
+	return authorizations
+}
+
+// SetStepUpAuthorizations loads authorizations saved by a previous run.
+func (a *Web) SetStepUpAuthorizations(authorizations []StepUpAuthorization) {
+	a.muStepUp.Lock()
+	defer a.muStepUp.Unlock()
+
+	for _, authorization := range authorizations {
+		if authorization.Valid() {
+			a.stepUps[authorization.CardReferenceID] = authorization
+		}
+	}
+}
+
+// saveStepUp records the step-up of card as expiring at expiresAt, or after
+// StepUpValidity if that is zero.
+func (a *Web) saveStepUp(card extension.PaymentCard, before []*http.Cookie, expiresAt time.Time) StepUpAuthorization {
+	previous := map[string]string{}
+	for _, cookie := range before {
+		previous[cookie.Name] = cookie.Value
+	}
+
+	cookies := []*http.Cookie{}
+	for _, cookie := range a.api.GetCookies() {
+		if value, ok := previous[cookie.Name]; !ok || value != cookie.Value {
+			cookies = append(cookies, cookie)
+		}
+	}
+
+	now := time.Now()
+	if expiresAt.IsZero() {
+		expiresAt = now.Add(StepUpValidity)
+	}
+
+	authorization := StepUpAuthorization{
+		CardReferenceID: card.CardReferenceID,
+		AuthorizedAt:    now,
+		ExpiresAt:       expiresAt,
+		Cookies:         cookies,
+	}
+
+	a.muStepUp.Lock()
+	a.stepUps[card.CardReferenceID] = authorization
+	a.muStepUp.Unlock()
+
+	return authorization
+}
+
+type StepUpOptions struct {
+	Provider otp.Provider
+	Select   otp.Selector
+	// MinRemainingAttempts stops retrying wrong codes once this many attempts
+	// remain.
+	MinRemainingAttempts int
+}
+
+type StepUpResult struct {
+	otp.Result
+	// Required is false when the assessment skipped the challenge or a saved
+	// authorization was reused
+	Required bool
+	// Reused is set when a saved authorization was reused
+	Reused bool
+	// ExpiresAt is when the authorization lapses. It is zero when the
+	// assessment skipped the challenge.
+	ExpiresAt time.Time
+}
+
+// StepUp completes the step-up challenge required to create virtual cards for
+// card, retrying wrong codes and re-sending codes as allowed by opts. A valid
+// authorization from an earlier step-up is reused instead.
+func (a *Web) StepUp(ctx context.Context, card extension.PaymentCard, opts StepUpOptions) (StepUpResult, error) {
+	if authorization, ok := a.StepUpAuthorization(card); ok {
+		a.api.SetCookies(authorization.Cookies)
+
+		return StepUpResult{
+			Result: otp.Result{
+				Accepted:          true,
+				ProfileStatus:     otp.ProfileStatusUnlocked,
+				RemainingAttempts: -1,
+			},
+			Reused:    true,
+			ExpiresAt: authorization.ExpiresAt,
+		}, nil
+	}
+
+	before := a.api.GetCookies()
+
+	assessment, err := a.ChallengeAssessment(ctx, card)
+	if err != nil {
+		return StepUpResult{}, err
+	}
+
+	authorized, err := assessment.Authorized()
+	if err != nil {
+		return StepUpResult{}, err
+	}
+
+	if authorized {
+		return StepUpResult{
+			Result: otp.Result{
+				Accepted:          true,
+				ProfileStatus:     otp.ProfileStatusUnlocked,
+				RemainingAttempts: -1,
+			},
+		}, nil
+	}
+
+	options := map[string]ChallengeOption{}
+	destinations := []otp.Destination{}
+	for _, option := range assessment.Options() {
+		if !option.Supported() {
+			a.api.Logger.Debug("Skipping unsupported challenge method", "method", option.String())
+			continue
+		}
+
+		label := option.String()
+		options[label] = option
+		destinations = append(destinations, otp.Destination{
+			Label:        label,
+			ContactPoint: option.ContactPoint.ContactPointMasked,
+			Medium:       string(option.Medium),
+		})
+	}
+
+	if len(destinations) == 0 {
+		return StepUpResult{Required: true}, fmt.Errorf("%w: no supported challenge methods found", ErrUnsupportedChallenge)
+	}
+
+	var expiresAt time.Time
+	result, err := otp.Run(ctx, opts.Provider, otp.Challenge[ChallengeVerificationResponse]{
+		Destinations: destinations,
+		Select:       opts.Select,
+		Send: func(ctx context.Context, destination otp.Destination) (ChallengeVerificationResponse, error) {
+			return a.ChallengeVerification(ctx, assessment.PolicyProcessID, options[destination.Label])
+		},
+		Validate: func(ctx context.Context, verification ChallengeVerificationResponse, code string) (otp.Result, error) {
+			result, at, err := a.challengeValidation(ctx, assessment.PolicyProcessID, verification.Otp, code)
+			expiresAt = at
+			return result, err
+		},
+		MinRemainingAttempts: opts.MinRemainingAttempts,
+	})
+	if err != nil {
+		return StepUpResult{Result: result, Required: true}, err
+	}
+
+	authorization := a.saveStepUp(card, before, expiresAt)
+
+	return StepUpResult{Result: result, Required: true, ExpiresAt: authorization.ExpiresAt}, nil
+}
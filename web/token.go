@@ -2,31 +2,67 @@ package web
 
 import (
 	"context"
+	"iter"
 	"net/http"
 	"net/url"
 	"strconv"
+	"time"
 
 	"github.com/saucesteals/eno/api"
 	"github.com/saucesteals/eno/extension"
 )
 
-func (a *Web) CreateToken(ctx context.Context, tokenName string, card extension.PaymentCard) (api.Token, error) {
+type TokenDuration string
+
+var (
+	TokenDurationUnlimited   TokenDuration = ""
+	TokenDurationOneMonth    TokenDuration = "ONE_MONTH"
+	TokenDurationThreeMonths TokenDuration = "THREE_MONTHS"
+	TokenDurationSixMonths   TokenDuration = "SIX_MONTHS"
+	TokenDurationOneYear     TokenDuration = "ONE_YEAR"
+
+	TokenDurations = []TokenDuration{
+		TokenDurationUnlimited,
+		TokenDurationOneMonth,
+		TokenDurationThreeMonths,
+		TokenDurationSixMonths,
+		TokenDurationOneYear,
+	}
+)
+
+type CreateTokenOptions struct {
+	// MdxID and MdxURLID bind the card to a merchant when set
+	MdxID      string
+	MdxURLID   string
+	OneTimeUse bool
+	Duration   TokenDuration
+}
+
+// WithMerchant binds the card to a merchant found with
+// extension.DataSourceSearch.
+func (o CreateTokenOptions) WithMerchant(merchant extension.DataSource) CreateTokenOptions {
+	o.MdxID = merchant.MDXId
+	o.MdxURLID = merchant.MDXUrlId
+	return o
+}
+
+func (a *Web) CreateToken(ctx context.Context, tokenName string, card extension.PaymentCard, opts CreateTokenOptions) (api.Token, error) {
 	type Payload struct {
-		CardReferenceID string `json:"cardReferenceId"`
-		IsOneTimeUse    bool   `json:"isOneTimeUse"`
-		MdxID           string `json:"mdxId"`
-		MdxURLID        string `json:"mdxUrlId"`
-		TokenCardName   string `json:"tokenCardName"`
-		TokenDuration   string `json:"tokenDuration"`
+		CardReferenceID string        `json:"cardReferenceId"`
+		IsOneTimeUse    bool          `json:"isOneTimeUse"`
+		MdxID           string        `json:"mdxId"`
+		MdxURLID        string        `json:"mdxUrlId"`
+		TokenCardName   string        `json:"tokenCardName"`
+		TokenDuration   TokenDuration `json:"tokenDuration"`
 	}
 
 	payload := Payload{
 		CardReferenceID: card.CardReferenceID,
-		MdxID:           "",
-		MdxURLID:        "",
+		MdxID:           opts.MdxID,
+		MdxURLID:        opts.MdxURLID,
 		TokenCardName:   tokenName,
-		IsOneTimeUse:    false,
-		TokenDuration:   "",
+		IsOneTimeUse:    opts.OneTimeUse,
+		TokenDuration:   opts.Duration,
 	}
 
 	clientKey, clientPrivateKey, err := a.GenerateJWK(ctx)
@@ -98,6 +134,11 @@ type ListedToken struct {
 	DurationHasPassed            bool    `json:"durationHasPassed"`
 	TokenStatus                  string  `json:"tokenStatus"`
 	MdxInfo                      MdxInfo `json:"mdxInfo"`
+
+	// TokenDuration and AllowAuthorizations are resent unchanged by updates
+	// when the listing includes them
+	TokenDuration       TokenDuration `json:"tokenDuration,omitempty"`
+	AllowAuthorizations *bool         `json:"allowAuthorizations,omitempty"`
 }
 
 type ListTokensResponse struct {
@@ -109,19 +150,27 @@ type ListTokensResponse struct {
 	UnfilteredCount int           `json:"unfilteredCount"`
 }
 
-func (a *Web) ListTokens(ctx context.Context, card extension.PaymentCard, nameFilter string, offset int, limit int) (ListTokensResponse, error) {
-	type FilterCriteria struct {
-		Field    string `json:"field"`
-		Operator string `json:"operator"`
-		Value    string `json:"value"`
-	}
+func parseTokenTimestamp(timestamp string) (time.Time, error) {
+	return time.ParseInLocation("2006-01-02T15:04:05", timestamp, time.UTC)
+}
 
+func (t ListedToken) CreatedAt() (time.Time, error) {
+	return parseTokenTimestamp(t.TokenCreatedTimestamp)
+}
+
+func (t ListedToken) UpdatedAt() (time.Time, error) {
+	return parseTokenTimestamp(t.TokenUpdatedTimestamp)
+}
+
+// ListTokens returns a single page of tokens. Use Tokens to iterate over every
+// page.
+func (a *Web) ListTokens(ctx context.Context, card extension.PaymentCard, tokenQuery TokenQuery, offset int, limit int) (ListTokensResponse, error) {
 	type Payload struct {
 		FilterCriteria  []FilterCriteria `json:"filterCriteria"`
 		ReferenceId     string           `json:"referenceId"`
 		ReferenceIdType string           `json:"referenceIdType"`
-		SortCriteria    []any            `json:"sortCriteria"`
-		TokenStatus     []any            `json:"tokenStatus"`
+		SortCriteria    []SortCriteria   `json:"sortCriteria"`
+		TokenStatus     []TokenStatus    `json:"tokenStatus"`
 	}
 
 	query := url.Values{}
@@ -133,17 +182,9 @@ func (a *Web) ListTokens(ctx context.Context, card extension.PaymentCard, nameFi
 		ReferenceId:     card.CardReferenceID,
 		ReferenceIdType: "ACCOUNT",
 
-		FilterCriteria: []FilterCriteria{},
-		SortCriteria:   []any{},
-		TokenStatus:    []any{},
-	}
-
-	if nameFilter != "" {
-		payload.FilterCriteria = append(payload.FilterCriteria, FilterCriteria{
-			Field:    "TOKEN_NAME",
-			Operator: "LIKE",
-			Value:    nameFilter,
-		})
+		FilterCriteria: tokenQuery.filterCriteria(),
+		SortCriteria:   tokenQuery.sortCriteria(),
+		TokenStatus:    tokenQuery.tokenStatuses(),
 	}
 
 	req, err := a.newWebRequest(ctx, http.MethodPost, "web-api/private/25419/commerce-virtual-numbers?"+query.Encode(), payload, nil)
@@ -158,3 +199,56 @@ func (a *Web) ListTokens(ctx context.Context, card extension.PaymentCard, nameFi
 
 	return response, nil
 }
+
+// Tokens iterates over every token matching tokenQuery, fetching the next page
+// in the background while the current one is consumed. Iteration stops after
+// the first error.
+func (a *Web) Tokens(ctx context.Context, card extension.PaymentCard, tokenQuery TokenQuery) iter.Seq2[ListedToken, error] {
+	type page struct {
+		response ListTokensResponse
+		err      error
+	}
+
+	return func(yield func(ListedToken, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		limit := tokenQuery.getPageSize(a.getPageSize())
+		fetch := func(offset int) <-chan page {
+			pages := make(chan page, 1)
+			go func() {
+				response, err := a.ListTokens(ctx, card, tokenQuery, offset, limit)
+				pages <- page{response: response, err: err}
+			}()
+			return pages
+		}
+
+		offset := 0
+		next := fetch(offset)
+		for next != nil {
+			current := <-next
+			if current.err != nil {
+				yield(ListedToken{}, current.err)
+				return
+			}
+
+			entries := current.response.Entries
+			offset += len(entries)
+
+			next = nil
+			if len(entries) > 0 && offset < current.response.Count {
+				next = fetch(offset)
+			}
+
+			for _, token := range entries {
+				if !tokenQuery.matchesLocal(token) {
+					continue
+				}
+
+				if !yield(token, nil) {
+					return
+				}
+			}
+		}
+	}
+}
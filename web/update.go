@@ -0,0 +1,117 @@
+package web
+
+import (
+	"context"
+
+	"github.com/saucesteals/eno/extension"
+)
+
+type TokenStatus string
+
+var (
+	TokenStatusActive    TokenStatus = "ACTIVE"
+	TokenStatusSuspended TokenStatus = "SUSPENDED"
+)
+
+// TokenState is the mutable state of a virtual card, enough to restore it with
+// RestoreToken.
+type TokenState struct {
+	TokenReferenceID    string `json:"tokenReferenceId"`
+	TokenName           string `json:"tokenName"`
+	TokenLastFour       string `json:"tokenLastFour"`
+	AllowAuthorizations bool   `json:"allowAuthorizations"`
+	MdxID               string `json:"mdxId"`
+	MdxURLID            string `json:"mdxUrlId"`
+
+	TokenDuration TokenDuration `json:"tokenDuration,omitempty"`
+}
+
+func NewTokenState(token ListedToken) TokenState {
+	return TokenState{
+		TokenReferenceID:    token.TokenReferenceID,
+		TokenName:           token.TokenName,
+		TokenLastFour:       token.TokenLastFour,
+		AllowAuthorizations: token.AllowsAuthorizations(),
+		MdxID:               token.MdxInfo.MdxID,
+		MdxURLID:            token.MdxInfo.MdxURLID,
+		TokenDuration:       token.TokenDuration,
+	}
+}
+
+// AllowsAuthorizations reports whether the token is not paused. Statuses other
+// than SUSPENDED, like expired ones, are not pauses and keep authorizations
+// allowed so updates do not pause them.
+func (t ListedToken) AllowsAuthorizations() bool {
+	if t.AllowAuthorizations != nil {
+		return *t.AllowAuthorizations
+	}
+
+	return TokenStatus(t.TokenStatus) != TokenStatusSuspended
+}
+
+func newUpdateTokenPayload(card extension.PaymentCard, state TokenState) UpdateTokenPayload {
+	var cardLastFour string
+	if len(card.CardNumber) >= 4 {
+		cardLastFour = card.CardNumber[len(card.CardNumber)-4:]
+	}
+
+	// Unlimited (or unlisted) durations are sent as null
+	var duration any
+	if state.TokenDuration != TokenDurationUnlimited {
+		duration = state.TokenDuration
+	}
+
+	return UpdateTokenPayload{
+		AllowAuthorizations: state.AllowAuthorizations,
+		CardLastFour:        cardLastFour,
+		CardName:            card.ProductDescription,
+		CardReferenceID:     card.CardReferenceID,
+		IsDeleted:           false,
+		MdxID:               state.MdxID,
+		MdxURLID:            state.MdxURLID,
+		TokenDuration:       duration,
+		TokenLastFour:       state.TokenLastFour,
+		TokenName:           state.TokenName,
+		TokenReferenceID:    state.TokenReferenceID,
+	}
+}
+
+// RestoreToken puts a token back into a previously captured state.
+func (a *Web) RestoreToken(ctx context.Context, card extension.PaymentCard, state TokenState) error {
+	return a.UpdateToken(ctx, newUpdateTokenPayload(card, state))
+}
+
+// SetTokenAuthorizations pauses (allow false) or resumes (allow true) a token.
+func (a *Web) SetTokenAuthorizations(ctx context.Context, card extension.PaymentCard, token ListedToken, allow bool) error {
+	update := newUpdateTokenPayload(card, NewTokenState(token))
+	update.AllowAuthorizations = allow
+	return a.UpdateToken(ctx, update)
+}
+
+func (a *Web) RenameToken(ctx context.Context, card extension.PaymentCard, token ListedToken, name string) error {
+	update := newUpdateTokenPayload(card, NewTokenState(token))
+	update.TokenName = name
+	return a.UpdateToken(ctx, update)
+}
+
+// BindToken restricts a token to merchant.
+func (a *Web) BindToken(ctx context.Context, card extension.PaymentCard, token ListedToken, merchant extension.DataSource) error {
+	update := newUpdateTokenPayload(card, NewTokenState(token))
+	update.MdxID = merchant.MDXId
+	update.MdxURLID = merchant.MDXUrlId
+	return a.UpdateToken(ctx, update)
+}
+
+// UnbindToken lets a token be used at any merchant.
+func (a *Web) UnbindToken(ctx context.Context, card extension.PaymentCard, token ListedToken) error {
+	update := newUpdateTokenPayload(card, NewTokenState(token))
+	update.MdxID = ""
+	update.MdxURLID = ""
+	return a.UpdateToken(ctx, update)
+}
+
+func (a *Web) DeleteToken(ctx context.Context, card extension.PaymentCard, token ListedToken) error {
+	update := newUpdateTokenPayload(card, NewTokenState(token))
+	update.IsDeleted = true
+	return a.UpdateToken(ctx, update)
+}
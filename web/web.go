@@ -13,6 +13,7 @@ import (
 	mrand "math/rand"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	http "github.com/saucesteals/fhttp"
@@ -25,12 +26,31 @@ type Web struct {
 	api *api.API
 
 	verifiedCCId string
+
+	stepUps  map[string]StepUpAuthorization
+	muStepUp sync.Mutex
+
+	pageSize int
 }
 
 func New(api *api.API) *Web {
 	return &Web{
-		api: api,
+		api:     api,
+		stepUps: map[string]StepUpAuthorization{},
+	}
+}
+
+// SetPageSize sets how many tokens are requested per page, 50 by default.
+func (a *Web) SetPageSize(size int) {
+	a.pageSize = size
+}
+
+func (a *Web) getPageSize() int {
+	if a.pageSize <= 0 {
+		return 50
 	}
+
+	return a.pageSize
 }
 
 func newSynchToken() string {
@@ -55,6 +75,7 @@ func (a *Web) newWebRequest(ctx context.Context, method, path string, body any,
 	synchToken := newSynchToken()
 
 	var bodyReader io.Reader
+	var cleartext []byte
 	if body != nil {
 		payload, err := json.Marshal(body)
 		if err != nil {
@@ -92,6 +113,7 @@ func (a *Web) newWebRequest(ctx context.Context, method, path string, body any,
 			if err != nil {
 				return nil, err
 			}
+			cleartext = protectedPayload
 
 			encrypted, err := encrypter.Encrypt(protectedPayload)
 			if err != nil {
@@ -114,6 +136,10 @@ func (a *Web) newWebRequest(ctx context.Context, method, path string, body any,
 		return nil, err
 	}
 
+	if isProtected && cleartext != nil {
+		a.api.HAR.AnnotateRequest(req, cleartext)
+	}
+
 	req.Header.Add("evt_synch_token", synchToken)
 	req.Header.Add("accept-language", "en-US,en;q=0.9")
 	req.Header.Add("cache-control", "no-cache, no-store, must-revalidate")
@@ -169,47 +195,56 @@ type protectedResponse struct {
 }
 
 func (a *Web) do(req *http.Request, body any, key *rsa.PrivateKey) error {
+	_, err := a.send(req, body, key)
+	return err
+}
+
+// send is do for callers that also need the response headers. The body of the
+// returned response is already closed.
+func (a *Web) send(req *http.Request, body any, key *rsa.PrivateKey) (*http.Response, error) {
 	res, err := a.api.Do(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer res.Body.Close()
 
 	if res.StatusCode > 299 {
-		return fmt.Errorf("%s %s: status code: %d", req.Method, req.URL.String(), res.StatusCode)
+		return res, fmt.Errorf("%s %s: status code: %d", req.Method, req.URL.String(), res.StatusCode)
 	}
 
 	if body != nil {
 		if key == nil {
-			return json.NewDecoder(res.Body).Decode(body)
+			return res, json.NewDecoder(res.Body).Decode(body)
 		}
 
 		response, err := io.ReadAll(res.Body)
 		if err != nil {
-			return err
+			return res, err
 		}
 
 		encrypted, err := jose.ParseEncrypted(string(response))
 		if err != nil {
-			return err
+			return res, err
 		}
 
 		decrypted, err := encrypted.Decrypt(key)
 		if err != nil {
-			return err
+			return res, err
 		}
 
+		a.api.HAR.AnnotateResponse(req, decrypted)
+
 		var protectedResponse protectedResponse
 		err = json.Unmarshal(decrypted, &protectedResponse)
 		if err != nil {
-			return err
+			return res, err
 		}
 
 		err = json.Unmarshal([]byte(protectedResponse.ResponseBody), body)
 		if err != nil {
-			return err
+			return res, err
 		}
 	}
 
-	return nil
+	return res, nil
 }